@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path"
@@ -15,7 +16,13 @@ import (
 	"github.com/rancher/charts-build-scripts/pkg/filesystem"
 	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
 	"github.com/samuelattwood/partner-charts-ci/pkg/fetcher"
+	"github.com/samuelattwood/partner-charts-ci/pkg/index"
+	"github.com/samuelattwood/partner-charts-ci/pkg/lint"
+	"github.com/samuelattwood/partner-charts-ci/pkg/oci"
 	"github.com/samuelattwood/partner-charts-ci/pkg/parse"
+	"github.com/samuelattwood/partner-charts-ci/pkg/report"
+	"github.com/samuelattwood/partner-charts-ci/pkg/scan"
+	"github.com/samuelattwood/partner-charts-ci/pkg/sign"
 	"github.com/samuelattwood/partner-charts-ci/pkg/validate"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -26,15 +33,20 @@ import (
 )
 
 const (
-	annotationAutoInstall  = "catalog.cattle.io/auto-install"
-	annotationCertified    = "catalog.cattle.io/certified"
-	annotationDisplayName  = "catalog.cattle.io/display-name"
-	annotationExperimental = "catalog.cattle.io/experimental"
-	annotationFeatured     = "catalog.cattle.io/featured"
-	annotationHidden       = "catalog.cattle.io/hidden"
-	annotationKubeVersion  = "catalog.cattle.io/kube-version"
-	annotationNamespace    = "catalog.cattle.io/namespace"
-	annotationReleaseName  = "catalog.cattle.io/release-name"
+	annotationAutoInstall    = "catalog.cattle.io/auto-install"
+	annotationCertified      = "catalog.cattle.io/certified"
+	annotationDisplayName    = "catalog.cattle.io/display-name"
+	annotationExperimental   = "catalog.cattle.io/experimental"
+	annotationFeatured       = "catalog.cattle.io/featured"
+	annotationHidden         = "catalog.cattle.io/hidden"
+	annotationKubeVersion    = "catalog.cattle.io/kube-version"
+	annotationNamespace      = "catalog.cattle.io/namespace"
+	annotationPermitsOS      = "catalog.cattle.io/permits-os"
+	annotationProvidesGVR    = "catalog.cattle.io/provides-gvr"
+	annotationRancherVersion = "catalog.cattle.io/rancher-version"
+	annotationReleaseName    = "catalog.cattle.io/release-name"
+	annotationRequestsCPU    = "catalog.cattle.io/requests-cpu"
+	annotationRequestsMemory = "catalog.cattle.io/requests-memory"
 	//indexFile sets the filename for the repo index yaml
 	indexFile = "index.yaml"
 	//packageEnvVariable sets the environment variable to check for a package name
@@ -43,13 +55,39 @@ const (
 	repositoryAssetsDir = "assets"
 	//repositoryChartsDir sets the directory name for stored charts
 	repositoryChartsDir = "charts"
+	//repositoryIconsDir sets the directory name for stored chart icons
+	repositoryIconsDir = "icons"
+	//repositoryIndexDir sets the directory name for per-vendor index shards
+	repositoryIndexDir = "index"
 	//repositoryPackagesDir sets the directory name for package configurations
 	repositoryPackagesDir = "packages"
-	configOptionsFile     = "configuration.yaml"
-	featuredMax           = 5
+	//signingKeyEnvVariable sets the environment variable to check for the path of the index signing key
+	signingKeyEnvVariable = "SIGNING_KEY"
+	//signingPassphraseEnvVariable sets the environment variable to check for the passphrase of an encrypted OpenPGP signing key
+	signingPassphraseEnvVariable = "SIGNING_KEY_PASSPHRASE"
+	configOptionsFile            = "configuration.yaml"
+	featuredMax                  = 5
 )
 
 var (
+	//managedAnnotations are set by the CI and exempt from forbidden annotation policies
+	managedAnnotations = []string{
+		annotationAutoInstall,
+		annotationCertified,
+		annotationDisplayName,
+		annotationExperimental,
+		annotationFeatured,
+		annotationHidden,
+		annotationKubeVersion,
+		annotationNamespace,
+		annotationPermitsOS,
+		annotationProvidesGVR,
+		annotationRancherVersion,
+		annotationReleaseName,
+		annotationRequestsCPU,
+		annotationRequestsMemory,
+	}
+
 	version = "v0.0.0"
 	commit  = "HEAD"
 )
@@ -72,8 +110,16 @@ type PackageWrapper struct {
 	ManualUpdate bool
 	//Chart name
 	Name string
+	//PackageVersion set in package.yaml
+	PackageVersion *int
+	//FixedVersion is the chart version set in package.yaml
+	FixedVersion string
 	//Untracked upstream versions newer than latest tracked
 	NewerUntracked []*semver.Version
+	//Paths of chart assets written to the assets directory
+	SavedAssets []string
+	//Versions converted from chart apiVersion v1 to v2
+	UpgradedVersions []string
 	//Force only pulling the latest version
 	OnlyLatest bool
 	//Indicator to write chart to disk
@@ -137,6 +183,13 @@ func (packageWrapper PackageWrapper) patch() error {
 		return err
 	}
 
+	//Overlay files applied when the package was prepared are not changes
+	//to the chart
+	err = conform.RemoveOverlayFiles(packageWrapper.Path)
+	if err != nil {
+		return err
+	}
+
 	if !packageWrapper.ManualUpdate {
 		packageYaml.Remove()
 	}
@@ -177,16 +230,13 @@ func (packageWrapper *PackageWrapper) populateManual() (bool, error) {
 		URLs: make([]string, 1),
 	}
 
-	fixedVersion := ""
+	packageWrapper.PackageVersion = pkg.PackageVersion
 	if pkg.Version != nil {
-		fixedVersion = pkg.Version.String()
+		packageWrapper.FixedVersion = pkg.Version.String()
 	}
 
 	chartVersion.URLs[0] = pkg.Upstream.GetOptions().URL
-	chartVersion.Version, err = conform.GeneratePackageVersion(
-		helmChart.Metadata.Version,
-		pkg.PackageVersion,
-		fixedVersion)
+	chartVersion.Version, err = publishedVersion(packageWrapper, helmChart.Metadata.Version)
 	if err != nil {
 		return false, err
 	}
@@ -197,7 +247,9 @@ func (packageWrapper *PackageWrapper) populateManual() (bool, error) {
 	packageWrapper.FetchVersions, err = filterVersions(
 		packageWrapper.SourceMetadata.Versions,
 		"",
-		nil)
+		nil,
+		0,
+		"")
 	if err != nil {
 		return false, err
 	}
@@ -251,7 +303,9 @@ func (packageWrapper *PackageWrapper) populate() (bool, error) {
 		packageWrapper.FetchVersions, err = filterVersions(
 			packageWrapper.SourceMetadata.Versions,
 			packageWrapper.UpstreamYaml.Fetch,
-			packageWrapper.UpstreamYaml.TrackVersions)
+			packageWrapper.UpstreamYaml.TrackVersions,
+			packageWrapper.UpstreamYaml.PackageVersion,
+			packageWrapper.UpstreamYaml.PackageVersionFormat)
 		if err != nil {
 			return false, err
 		}
@@ -275,13 +329,25 @@ func (packageWrapper *PackageWrapper) populate() (bool, error) {
 	return true, nil
 }
 
-func (packageWrapper PackageWrapper) annotate(annotation, value string, remove, onlyLatest bool) error {
+// Returns the values set in the package's upstream.yaml file. Packages using
+// package.yaml have no upstream.yaml, so the zero value is returned for them
+func (packageWrapper PackageWrapper) upstreamOptions() parse.UpstreamYaml {
+	if packageWrapper.UpstreamYaml == nil {
+		return parse.UpstreamYaml{}
+	}
+
+	return *packageWrapper.UpstreamYaml
+}
+
+// Modifies an annotation of stored chart versions and updates their index
+// entries. The index is not written
+func (packageWrapper PackageWrapper) annotate(indexManager *index.Manager, annotation, value string, remove, onlyLatest bool) error {
 	var versionsToUpdate repo.ChartVersions
 	chartName := packageWrapper.LatestStored.Name
 
-	allStoredVersions, err := getStoredVersions(chartName)
-	if err != nil {
-		return err
+	allStoredVersions := indexManager.IndexFile.Entries[chartName]
+	if len(allStoredVersions) == 0 {
+		return fmt.Errorf("%s not present in index entries", chartName)
 	}
 
 	if onlyLatest {
@@ -319,21 +385,25 @@ func (packageWrapper PackageWrapper) annotate(annotation, value string, remove,
 		if modified {
 			logrus.Debugf("Modified annotations of %s (%s)\n", packageWrapper.Name, helmChart.Metadata.Version)
 
-			err = os.RemoveAll(versionPath)
-			if err != nil {
-				return err
-			}
-
 			err = conform.ExportChartAsset(helmChart, assetsPath)
 			if err != nil {
 				return err
 			}
-			conform.ExportChartDirectory(helmChart, versionPath)
-			if err != nil {
-				return err
+			assetFile := filepath.Join(assetsPath, fmt.Sprintf("%s-%s.tgz", helmChart.Name(), helmChart.Metadata.Version))
+
+			//The chart directory holds the latest version only
+			if helmChart.Metadata.Version == packageWrapper.LatestStored.Version {
+				err = os.RemoveAll(versionPath)
+				if err != nil {
+					return err
+				}
+				err = exportChartDirectory(helmChart, assetFile, versionPath)
+				if err != nil {
+					return err
+				}
 			}
 
-			err = removeVersionFromIndex(chartName, *version)
+			err = indexManager.Add(assetFile)
 			if err != nil {
 				return err
 			}
@@ -341,9 +411,7 @@ func (packageWrapper PackageWrapper) annotate(annotation, value string, remove,
 
 	}
 
-	err = writeIndex()
-
-	return err
+	return nil
 }
 
 // Fetches absolute repository root path
@@ -434,6 +502,15 @@ func commitChanges(updatedList PackageList) error {
 		wt.Add(chartsPath)
 		wt.Add(packagesPath)
 
+		if packageWrapper.UpstreamYaml != nil && packageWrapper.UpstreamYaml.SplitCRDs {
+			wt.Add(chartsPath + conform.CRDChartSuffix)
+		}
+
+		iconsPath := path.Join(repositoryIconsDir, packageWrapper.ParsedVendor)
+		if _, err := os.Stat(filepath.Join(getRepoRoot(), iconsPath)); err == nil {
+			wt.Add(iconsPath)
+		}
+
 		gitStatus, err := wt.Status()
 		if err != nil {
 			return err
@@ -450,7 +527,10 @@ func commitChanges(updatedList PackageList) error {
 
 	}
 
-	wt.Add(indexFile)
+	err = stageIndexFiles(wt)
+	if err != nil {
+		return err
+	}
 
 	commitMessage := "Charts CI\n```"
 	sort.Sort(updatedList)
@@ -494,6 +574,96 @@ func commitChanges(updatedList PackageList) error {
 	return nil
 }
 
+// Stages the index and the files written alongside it, if present: index
+// shards, asset checksums, and their signatures
+func stageIndexFiles(wt *git.Worktree) error {
+	checksumsFile := path.Join(repositoryAssetsDir, sign.ChecksumsFile)
+	indexFiles := []string{indexFile, repositoryIndexDir, checksumsFile}
+	for _, extension := range []string{sign.OpenPGPExtension, sign.Ed25519Extension} {
+		indexFiles = append(indexFiles, indexFile+extension, checksumsFile+extension)
+	}
+
+	for _, filePath := range indexFiles {
+		if _, err := os.Stat(filepath.Join(getRepoRoot(), filePath)); err != nil {
+			continue
+		}
+		_, err := wt.Add(filePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Commits the index and the given repository paths, listing the changed
+// versions of each <vendor>/<chart> under heading in the commit message.
+// Files deleted under paths that no longer exist are removed
+func commitIndexChanges(heading string, changedVersions map[string][]string, changedPaths []string) error {
+	commitOptions := git.CommitOptions{}
+
+	r, err := git.PlainOpen(getRepoRoot())
+	if err != nil {
+		return err
+	}
+
+	wt, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	logrus.Info("Committing changes")
+
+	gitStatus, err := wt.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, changedPath := range changedPaths {
+		if _, err := os.Stat(filepath.Join(getRepoRoot(), changedPath)); !os.IsNotExist(err) {
+			_, err = wt.Add(changedPath)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		for f, s := range gitStatus {
+			if s.Worktree != git.Deleted || (f != changedPath && !strings.HasPrefix(f, changedPath+"/")) {
+				continue
+			}
+			_, err = wt.Remove(f)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = stageIndexFiles(wt)
+	if err != nil {
+		return err
+	}
+
+	chartNames := make([]string, 0, len(changedVersions))
+	for chartName := range changedVersions {
+		chartNames = append(chartNames, chartName)
+	}
+	sort.Strings(chartNames)
+
+	changes := ""
+	for _, chartName := range chartNames {
+		changes += fmt.Sprintf("  %s:\n", chartName)
+		for _, version := range changedVersions[chartName] {
+			changes += fmt.Sprintf("    - %s\n", version)
+		}
+	}
+
+	commitMessage := fmt.Sprintf("Charts CI\n```\n%s:\n%s```", heading, changes)
+
+	_, err = wt.Commit(commitMessage, &commitOptions)
+
+	return err
+}
+
 // Cleans up ephemeral chart directory files from package prepare
 func cleanPackage(packagePath string, manualUpdate bool) error {
 	packageName := strings.TrimPrefix(getRelativePath(packagePath), "/")
@@ -523,7 +693,10 @@ func prepareManualPackage(packagePath string) error {
 		logrus.Error(err)
 	}
 
-	conform.LinkOverlayFiles(packagePath)
+	err = conform.RemoveOverlayFiles(packagePath)
+	if err != nil {
+		logrus.Error(err)
+	}
 
 	err = pkg.Prepare()
 	if err != nil {
@@ -634,7 +807,9 @@ func collectTrackedVersions(upstreamVersions repo.ChartVersions, tracked []strin
 	return trackedVersions
 }
 
-func collectNonStoredVersions(versions repo.ChartVersions, storedVersions repo.ChartVersions, fetch string) repo.ChartVersions {
+// Returns the upstream versions that are not stored. Stored versions are only
+// decoded if the package encodes a package version, in the package's format
+func collectNonStoredVersions(versions repo.ChartVersions, storedVersions repo.ChartVersions, fetch string, packageVersion int, packageVersionFormat string) repo.ChartVersions {
 	nonStoredVersions := make(repo.ChartVersions, 0)
 	for i, version := range versions {
 		parsedVersion, err := semver.NewVersion(version.Version)
@@ -644,7 +819,7 @@ func collectNonStoredVersions(versions repo.ChartVersions, storedVersions repo.C
 		stored := false
 		logrus.Debugf("Checking if version %s is stored\n", version.Version)
 		for _, storedVersion := range storedVersions {
-			strippedStoredVersion := conform.StripPackageVersion(storedVersion.Version)
+			strippedStoredVersion := conform.StripPackageVersion(storedVersion.Version, packageVersion, packageVersionFormat)
 			if storedVersion.Version == parsedVersion.String() {
 				logrus.Debugf("Found version %s\n", storedVersion.Version)
 				stored = true
@@ -668,7 +843,7 @@ func collectNonStoredVersions(versions repo.ChartVersions, storedVersions repo.C
 					continue
 				}
 				if len(storedVersions) > 0 {
-					strippedStoredLatest := conform.StripPackageVersion(storedVersions[0].Version)
+					strippedStoredLatest := conform.StripPackageVersion(storedVersions[0].Version, packageVersion, packageVersionFormat)
 					storedLatestSemVer, err := semver.NewVersion(strippedStoredLatest)
 					if err != nil {
 						logrus.Error(err)
@@ -734,7 +909,7 @@ func checkNewerUntracked(tracked []string, upstreamVersions repo.ChartVersions)
 
 }
 
-func filterVersions(upstreamVersions repo.ChartVersions, fetch string, tracked []string) (repo.ChartVersions, error) {
+func filterVersions(upstreamVersions repo.ChartVersions, fetch string, tracked []string, packageVersion int, packageVersionFormat string) (repo.ChartVersions, error) {
 	logrus.Debugf("Filtering versions for %s\n", upstreamVersions[0].Name)
 	upstreamVersions = stripPreRelease(upstreamVersions)
 	if len(tracked) > 0 {
@@ -757,11 +932,11 @@ func filterVersions(upstreamVersions repo.ChartVersions, fetch string, tracked [
 			return filteredVersions, err
 		}
 		for _, trackedVersion := range tracked {
-			nonStoredVersions := collectNonStoredVersions(allTrackedVersions[trackedVersion], storedTrackedVersions[trackedVersion], fetch)
+			nonStoredVersions := collectNonStoredVersions(allTrackedVersions[trackedVersion], storedTrackedVersions[trackedVersion], fetch, packageVersion, packageVersionFormat)
 			filteredVersions = append(filteredVersions, nonStoredVersions...)
 		}
 	} else {
-		filteredVersions = collectNonStoredVersions(upstreamVersions, allStoredVersions, fetch)
+		filteredVersions = collectNonStoredVersions(upstreamVersions, allStoredVersions, fetch, packageVersion, packageVersionFormat)
 	}
 
 	return filteredVersions, nil
@@ -800,14 +975,30 @@ func parseVendor(upstreamYamlVendor, chartName, packagePath string) (string, str
 	return vendor, parsedVendor
 }
 
+// Returns the version a chart is published as. The PackageVersion set in
+// package.yaml or upstream.yaml is encoded in the upstream version, unless
+// package.yaml sets a fixed version
+func publishedVersion(packageWrapper *PackageWrapper, upstreamVersion string) (string, error) {
+	if packageWrapper.ManualUpdate {
+		return conform.GeneratePackageVersion(upstreamVersion, packageWrapper.PackageVersion, packageWrapper.FixedVersion, conform.PackageVersionPatch)
+	}
+	packageVersion := packageWrapper.UpstreamYaml.PackageVersion
+	if packageVersion == 0 {
+		return upstreamVersion, nil
+	}
+
+	return conform.GeneratePackageVersion(upstreamVersion, &packageVersion, "", packageWrapper.UpstreamYaml.PackageVersionFormat)
+}
+
 // Prepares and standardizes chart, then returns loaded chart object
-func initializeChart(packagePath string, sourceMetadata fetcher.ChartSourceMetadata, chartVersion repo.ChartVersion, manualUpdate bool) (*chart.Chart, error) {
+func initializeChart(packageWrapper *PackageWrapper, chartVersion repo.ChartVersion) (*chart.Chart, error) {
 	var err error
-	if manualUpdate {
+	packagePath := packageWrapper.Path
+	if packageWrapper.ManualUpdate {
 		err = prepareManualPackage(packagePath)
 
 	} else {
-		err = preparePackage(packagePath, &sourceMetadata, &chartVersion)
+		err = preparePackage(packagePath, packageWrapper.SourceMetadata, &chartVersion)
 	}
 	if err != nil {
 		return nil, err
@@ -816,7 +1007,46 @@ func initializeChart(packagePath string, sourceMetadata fetcher.ChartSourceMetad
 	chartDirectoryPath := path.Join(packagePath, repositoryChartsDir)
 	conform.StandardizeChartDirectory(chartDirectoryPath, "")
 
-	err = conform.ApplyOverlayFiles(packagePath)
+	//Patches are generated before overlays are applied so that overlay files
+	//are not added to the generated changes
+	if packageWrapper.ManualUpdate && packageWrapper.GenPatch {
+		pkg, err := generatePackage(packagePath)
+		if err != nil {
+			return nil, err
+		}
+		err = pkg.GeneratePatch()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	upstreamChart, err := loader.Load(chartDirectoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	upstreamVersion := chartVersion.Version
+	if packageWrapper.ManualUpdate {
+		upstreamVersion = upstreamChart.Metadata.Version
+	}
+	version, err := publishedVersion(packageWrapper, upstreamVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	overlayData := conform.OverlayData{
+		Name:            upstreamChart.Name(),
+		Version:         version,
+		UpstreamVersion: upstreamVersion,
+		AppVersion:      upstreamChart.Metadata.AppVersion,
+		Vendor:          packageWrapper.Vendor,
+		Values:          upstreamChart.Values,
+	}
+	if len(chartVersion.URLs) > 0 {
+		overlayData.SourceURL = chartVersion.URLs[0]
+	}
+
+	err = conform.ApplyOverlayFiles(packagePath, overlayData)
 	if err != nil {
 		return nil, err
 	}
@@ -832,54 +1062,60 @@ func initializeChart(packagePath string, sourceMetadata fetcher.ChartSourceMetad
 }
 
 // Mutates chart with necessary alterations for repository
-func conformPackage(packageWrapper PackageWrapper) error {
+func conformPackage(packageWrapper *PackageWrapper, indexManager *index.Manager, kubeSchemas *lint.KubeSchemas, configYaml validate.ConfigurationYaml) error {
 	var err error
 	logrus.Debugf("Conforming package from %s\n", packageWrapper.Path)
+	upstreamYaml := packageWrapper.upstreamOptions()
+	conformedCharts := make([]*chart.Chart, 0, len(packageWrapper.FetchVersions))
+	migrateFeatured := false
 	for _, chartVersion := range packageWrapper.FetchVersions {
 		logrus.Debugf("Conforming package %s (%s)\n", chartVersion.Name, chartVersion.Version)
-		helmChart, err := initializeChart(
-			packageWrapper.Path,
-			*packageWrapper.SourceMetadata,
-			*chartVersion,
-			packageWrapper.ManualUpdate,
-		)
+		var crdChart *chart.Chart
+		helmChart, err := initializeChart(packageWrapper, *chartVersion)
 		if err != nil {
 			return err
 		}
 
-		if autoInstall := packageWrapper.UpstreamYaml.AutoInstall; autoInstall != "" {
+		if upstreamYaml.UpgradeAPIVersion {
+			upgraded, err := conform.UpgradeChartAPIVersion(helmChart)
+			if err != nil {
+				return err
+			}
+			if upgraded {
+				logrus.Infof("Upgraded %s (%s) to chart apiVersion %s\n", helmChart.Name(), chartVersion.Version, chart.APIVersionV2)
+				packageWrapper.UpgradedVersions = append(packageWrapper.UpgradedVersions, chartVersion.Version)
+			}
+		}
+
+		if autoInstall := upstreamYaml.AutoInstall; autoInstall != "" {
 			packageWrapper.Annotations[annotationAutoInstall] = autoInstall
 		}
 
-		if packageWrapper.UpstreamYaml.Experimental {
+		if upstreamYaml.Experimental {
 			packageWrapper.Annotations[annotationExperimental] = "true"
 		}
 
-		if packageWrapper.UpstreamYaml.Hidden {
+		if upstreamYaml.Hidden {
 			packageWrapper.Annotations[annotationHidden] = "true"
 		}
 
-		if !packageWrapper.UpstreamYaml.RemoteDependencies {
+		if !upstreamYaml.RemoteDependencies {
+			err = fetcher.VendorDependencies(helmChart)
+			if err != nil {
+				return err
+			}
 			for _, d := range helmChart.Metadata.Dependencies {
 				d.Repository = fmt.Sprintf("file://./charts/%s", d.Name)
 			}
+			err = conform.GenerateChartLock(helmChart)
+			if err != nil {
+				return err
+			}
 		}
 
 		if packageWrapper.ManualUpdate {
 			packageWrapper.Name = helmChart.Name()
 			chartVersion.Version = helmChart.Metadata.Version
-			pkg, err := generatePackage(packageWrapper.Path)
-			if err != nil {
-				return err
-			}
-
-			if packageWrapper.GenPatch {
-				err = pkg.GeneratePatch()
-				if err != nil {
-					return err
-				}
-
-			}
 
 		} else {
 			packageWrapper.Annotations[annotationCertified] = "partner"
@@ -892,16 +1128,50 @@ func conformPackage(packageWrapper PackageWrapper) error {
 
 			conform.OverlayChartMetadata(helmChart, packageWrapper.UpstreamYaml.ChartYaml)
 
-			if val, ok := getByAnnotation(annotationFeatured, "")[packageWrapper.Name]; ok {
-				logrus.Debugf("Migrating featured annotation to latest version %s\n", packageWrapper.Name)
-				featuredIndex := val[0].Annotations[annotationFeatured]
-				packageWrapper.annotate(annotationFeatured, "", true, false)
-				packageWrapper.Annotations[annotationFeatured] = featuredIndex
+			if conform.GenerateAppReadme(helmChart, packageWrapper.UpstreamYaml.AppReadmeLength) {
+				logrus.Warnf("%s not provided for %s (%s). Generated from README.md\n",
+					conform.AppReadmeFile, packageWrapper.Name, helmChart.Metadata.Version)
+			}
+
+			if configYaml.Icons.Mode != "" {
+				err = conformIcon(helmChart, packageWrapper.ParsedVendor, configYaml.Icons)
+				if err != nil {
+					logrus.Warnf("Unable to %s icon of %s (%s): %s\n", configYaml.Icons.Mode, packageWrapper.Name, helmChart.Metadata.Version, err)
+				}
+			}
+
+			//The index is only loaded when charts are saved
+			if indexManager != nil {
+				if val, ok := getByAnnotation(indexManager.IndexFile, annotationFeatured, "")[packageWrapper.Name]; ok {
+					packageWrapper.Annotations[annotationFeatured] = val[0].Annotations[annotationFeatured]
+					migrateFeatured = true
+				}
 			}
 
 			if packageWrapper.UpstreamYaml.Namespace != "" {
 				packageWrapper.Annotations[annotationNamespace] = packageWrapper.UpstreamYaml.Namespace
 			}
+			if packageWrapper.UpstreamYaml.RancherVersion != "" {
+				packageWrapper.Annotations[annotationRancherVersion] = packageWrapper.UpstreamYaml.RancherVersion
+			}
+
+			rendered, err := conform.RenderChart(helmChart, packageWrapper.UpstreamYaml.Namespace, "")
+			if err != nil {
+				logrus.Debugf("Unable to render %s (%s) to infer annotations: %s\n", packageWrapper.Name, helmChart.Metadata.Version, err)
+			}
+			requirements := conform.InferChartRequirements(conform.ParseManifests(helmChart, rendered))
+			inferredAnnotations := make(map[string]string)
+			for annotation, value := range map[string]string{
+				annotationProvidesGVR:    strings.Join(requirements.ProvidesGVR, ","),
+				annotationRequestsCPU:    requirements.RequestsCPU,
+				annotationRequestsMemory: requirements.RequestsMemory,
+				annotationPermitsOS:      strings.Join(requirements.PermitsOS, ","),
+			} {
+				if value != "" {
+					inferredAnnotations[annotation] = value
+				}
+			}
+
 			if helmChart.Metadata.KubeVersion != "" && packageWrapper.UpstreamYaml.ChartYaml.KubeVersion != "" {
 				packageWrapper.Annotations[annotationKubeVersion] = packageWrapper.UpstreamYaml.ChartYaml.KubeVersion
 				helmChart.Metadata.KubeVersion = packageWrapper.UpstreamYaml.ChartYaml.KubeVersion
@@ -911,91 +1181,274 @@ func conformPackage(packageWrapper PackageWrapper) error {
 				packageWrapper.Annotations[annotationKubeVersion] = packageWrapper.UpstreamYaml.ChartYaml.KubeVersion
 			}
 
-			if packageVersion := packageWrapper.UpstreamYaml.PackageVersion; packageVersion != 0 {
-				helmChart.Metadata.Version, err = conform.GeneratePackageVersion(helmChart.Metadata.Version, &packageVersion, "")
+			helmChart.Metadata.Version, err = publishedVersion(packageWrapper, helmChart.Metadata.Version)
+			if err != nil {
+				return err
+			}
+
+			if packageWrapper.UpstreamYaml.SplitCRDs {
+				crdChart, err = conform.SplitCRDChart(helmChart)
 				if err != nil {
-					logrus.Error(err)
+					return err
+				}
+				if crdChart != nil {
+					if packageWrapper.UpstreamYaml.AutoInstall != "" {
+						logrus.Warnf("AutoInstall '%s' replaced by CRD chart %s\n", packageWrapper.UpstreamYaml.AutoInstall, crdChart.Name())
+					}
+					crdAnnotations := map[string]string{
+						annotationCertified:   "partner",
+						annotationHidden:      "true",
+						annotationReleaseName: packageWrapper.Annotations[annotationReleaseName] + conform.CRDChartSuffix,
+					}
+					for _, annotation := range []string{annotationKubeVersion, annotationNamespace} {
+						if value, ok := packageWrapper.Annotations[annotation]; ok {
+							crdAnnotations[annotation] = value
+						}
+					}
+					conform.ApplyChartAnnotations(crdChart, crdAnnotations, true)
+					packageWrapper.Annotations[annotationAutoInstall] = fmt.Sprintf("%s=match", crdChart.Name())
+				} else {
+					logrus.Warnf("SplitCRDs set but no CRDs found in %s (%s)\n", packageWrapper.Name, helmChart.Metadata.Version)
+					if autoInstall := packageWrapper.UpstreamYaml.AutoInstall; autoInstall != "" {
+						packageWrapper.Annotations[annotationAutoInstall] = autoInstall
+					} else {
+						delete(packageWrapper.Annotations, annotationAutoInstall)
+					}
 				}
 			}
 
-			conform.ApplyChartAnnotations(helmChart, packageWrapper.Annotations, false)
+			//Inferred annotations never replace values set upstream or in upstream.yaml
+			conform.ApplyChartAnnotations(helmChart, inferredAnnotations, false)
+
+			err = applyAnnotationPolicy(helmChart, packageWrapper.Annotations, configYaml.AnnotationPolicy)
+			if err != nil {
+				return err
+			}
+
+			if crdChart != nil {
+				conform.ApplyChartAnnotations(helmChart, map[string]string{
+					annotationAutoInstall: packageWrapper.Annotations[annotationAutoInstall],
+				}, true)
+			}
 
 		}
 
-		if packageWrapper.Save {
-			err = cleanPackage(packageWrapper.Path, packageWrapper.ManualUpdate)
+		for _, verifiedChart := range []*chart.Chart{helmChart, crdChart} {
+			if verifiedChart == nil {
+				continue
+			}
+			err = lint.Verify(verifiedChart, upstreamYaml.Namespace)
+			if err != nil {
+				return err
+			}
+			err = checkKubeAPIs(verifiedChart, kubeSchemas)
 			if err != nil {
-				logrus.Debug(err)
+				return err
 			}
+		}
 
-			assetsPath := filepath.Join(
-				getRepoRoot(),
-				repositoryAssetsDir,
-				packageWrapper.ParsedVendor)
+		for _, scannedChart := range []*chart.Chart{helmChart, crdChart} {
+			if scannedChart == nil {
+				continue
+			}
+			blockingFindings := 0
+			for _, finding := range scan.Chart(scannedChart, upstreamYaml.Namespace, upstreamYaml.ScanSuppressions) {
+				if configYaml.ScanThreshold != "" && finding.AtLeast(configYaml.ScanThreshold) {
+					logrus.Errorf("%s (%s): %s\n", scannedChart.Name(), scannedChart.Metadata.Version, finding)
+					blockingFindings++
+				} else {
+					logrus.Warnf("%s (%s): %s\n", scannedChart.Name(), scannedChart.Metadata.Version, finding)
+				}
+			}
+			if blockingFindings > 0 {
+				return fmt.Errorf("%s (%s) has %d security findings at or above %s severity",
+					scannedChart.Name(), scannedChart.Metadata.Version, blockingFindings, configYaml.ScanThreshold)
+			}
+		}
+
+		conformedCharts = append(conformedCharts, helmChart)
+		if crdChart != nil {
+			conformedCharts = append(conformedCharts, crdChart)
+		}
+	}
+
+	//Charts are only saved once every version is verified, so that a failing
+	//version does not leave earlier versions saved and indexed
+	if packageWrapper.Save && len(conformedCharts) > 0 {
+		err = cleanPackage(packageWrapper.Path, packageWrapper.ManualUpdate)
+		if err != nil {
+			logrus.Debug(err)
+		}
+
+		//The featured annotation is only moved from stored versions once the
+		//versions replacing them are verified
+		if migrateFeatured {
+			logrus.Debugf("Migrating featured annotation to latest version %s\n", packageWrapper.Name)
+			err = packageWrapper.annotate(indexManager, annotationFeatured, "", true, false)
+			if err != nil {
+				logrus.Error(err)
+			}
+		}
+
+		assetsPath := filepath.Join(
+			getRepoRoot(),
+			repositoryAssetsDir,
+			packageWrapper.ParsedVendor)
 
+		for _, savedChart := range conformedCharts {
 			chartsPath := filepath.Join(
 				getRepoRoot(),
 				repositoryChartsDir,
 				packageWrapper.ParsedVendor,
-				helmChart.Metadata.Name)
+				savedChart.Metadata.Name)
 
 			if _, err := os.Stat(chartsPath); !os.IsNotExist(err) {
 				os.RemoveAll(chartsPath)
 			}
 
-			err = saveChart(helmChart, assetsPath, chartsPath)
+			assetFile, err := saveChart(savedChart, assetsPath, chartsPath)
 			if err != nil {
 				return err
 			}
+			packageWrapper.SavedAssets = append(packageWrapper.SavedAssets, assetFile)
 		}
-
 	}
 
-	return err
+	return nil
 }
 
-// Saves chart to disk as asset gzip and directory
-func saveChart(helmChart *chart.Chart, assetsPath, chartsPath string) error {
+// Downloads and validates the chart icon, then rewrites it to a data URI or
+// stores it in the icons directory, depending on the configured mode
+func conformIcon(helmChart *chart.Chart, parsedVendor string, iconConfig validate.IconConfig) error {
+	iconURL := helmChart.Metadata.Icon
+	if iconURL == "" {
+		return fmt.Errorf("no icon set")
+	}
+	if strings.HasPrefix(iconURL, "file://") {
+		return nil
+	}
 
-	logrus.Debugf("Exporting chart assets to %s\n", assetsPath)
-	err := conform.ExportChartAsset(helmChart, assetsPath)
+	icon, err := fetcher.FetchIcon(iconURL, iconConfig.MaxSize)
 	if err != nil {
 		return err
 	}
 
-	assetFile := fmt.Sprintf("%s-%s.tgz", helmChart.Name(), helmChart.Metadata.Version)
-	assetFile = path.Join(assetsPath, assetFile)
+	switch iconConfig.Mode {
+	case validate.IconEmbed:
+		helmChart.Metadata.Icon = icon.DataURI()
+	case validate.IconStore:
+		iconsPath := filepath.Join(getRepoRoot(), repositoryIconsDir, parsedVendor)
+		iconName := fmt.Sprintf("%s.%s", helmChart.Name(), icon.Extension())
+		iconPath := filepath.Join(iconsPath, iconName)
 
-	err = conform.Gunzip(assetFile, chartsPath)
-	if err != nil {
-		logrus.Error(err)
-	}
+		//Remove icons of the chart stored with another format
+		staleIcons, err := filepath.Glob(filepath.Join(iconsPath, helmChart.Name()+".*"))
+		if err != nil {
+			return err
+		}
+		for _, staleIcon := range staleIcons {
+			if staleIcon != iconPath {
+				os.Remove(staleIcon)
+			}
+		}
 
-	logrus.Debugf("Exporting chart to %s\n", chartsPath)
-	err = conform.ExportChartDirectory(helmChart, chartsPath)
-	if err != nil {
-		return err
+		if existing, err := os.ReadFile(iconPath); err != nil || !bytes.Equal(existing, icon.Data) {
+			if err = os.MkdirAll(iconsPath, 0755); err != nil {
+				return err
+			}
+			if err = os.WriteFile(iconPath, icon.Data, 0644); err != nil {
+				return err
+			}
+		}
+		helmChart.Metadata.Icon = "file://" + path.Join(repositoryIconsDir, parsedVendor, iconName)
+	default:
+		return fmt.Errorf("unknown icon mode '%s'", iconConfig.Mode)
 	}
 
 	return nil
 }
 
-func getLatestTracked(tracked []string) *semver.Version {
-	var latestTracked *semver.Version
-	for _, version := range tracked {
-		semVer, err := semver.NewVersion(version)
-		if err != nil {
-			logrus.Error(err)
+// Applies annotations to the chart, overriding values set by the chart vendor
+// unless overridable by the annotation policy. Forbidden annotations are
+// removed, and an error is returned if the chart still violates the policy
+func applyAnnotationPolicy(helmChart *chart.Chart, annotations map[string]string, policy validate.AnnotationPolicy) error {
+	vendorAnnotations := make(map[string]string)
+	enforcedAnnotations := make(map[string]string)
+	for annotation, value := range annotations {
+		if policy.IsOverridable(annotation) {
+			vendorAnnotations[annotation] = value
+		} else {
+			enforcedAnnotations[annotation] = value
 		}
-		if latestTracked == nil || semVer.GreaterThan(latestTracked) {
-			latestTracked = semVer
+	}
+	conform.ApplyChartAnnotations(helmChart, vendorAnnotations, false)
+	conform.ApplyChartAnnotations(helmChart, enforcedAnnotations, true)
+
+	for annotation := range helmChart.Metadata.Annotations {
+		if policy.IsForbidden(annotation) {
+			logrus.Warnf("Removing forbidden annotation %s from %s (%s)\n", annotation, helmChart.Name(), helmChart.Metadata.Version)
+			conform.RemoveChartAnnotations(helmChart, map[string]string{annotation: ""})
 		}
 	}
 
-	return latestTracked
+	if violations := policy.Check(helmChart.Metadata.Annotations); len(violations) > 0 {
+		return fmt.Errorf("%s (%s) violates annotation policy: %s",
+			helmChart.Name(), helmChart.Metadata.Version, strings.Join(violations, "; "))
+	}
+
+	return nil
 }
 
-func getStoredVersions(chartName string) (repo.ChartVersions, error) {
+// Saves chart to disk as asset gzip and directory. Returns the asset path
+func saveChart(helmChart *chart.Chart, assetsPath, chartsPath string) (string, error) {
+
+	logrus.Debugf("Exporting chart assets to %s\n", assetsPath)
+	err := conform.ExportChartAsset(helmChart, assetsPath)
+	if err != nil {
+		return "", err
+	}
+
+	assetFile := fmt.Sprintf("%s-%s.tgz", helmChart.Name(), helmChart.Metadata.Version)
+	assetFile = path.Join(assetsPath, assetFile)
+
+	err = exportChartDirectory(helmChart, assetFile, chartsPath)
+	if err != nil {
+		return "", err
+	}
+
+	return assetFile, nil
+}
+
+// Writes the chart directory of a chart from its asset. The asset is extracted
+// and the chart exported over it, so that stored and newly saved charts have
+// the same chart directory
+func exportChartDirectory(helmChart *chart.Chart, assetFile, chartsPath string) error {
+	err := conform.Gunzip(assetFile, chartsPath)
+	if err != nil {
+		logrus.Error(err)
+	}
+
+	logrus.Debugf("Exporting chart to %s\n", chartsPath)
+
+	return conform.ExportChartDirectory(helmChart, chartsPath)
+}
+
+func getLatestTracked(tracked []string) *semver.Version {
+	var latestTracked *semver.Version
+	for _, version := range tracked {
+		semVer, err := semver.NewVersion(version)
+		if err != nil {
+			logrus.Error(err)
+		}
+		if latestTracked == nil || semVer.GreaterThan(latestTracked) {
+			latestTracked = semVer
+		}
+	}
+
+	return latestTracked
+}
+
+func getStoredVersions(chartName string) (repo.ChartVersions, error) {
 	helmIndexYaml, err := readIndex()
 	storedVersions := repo.ChartVersions{}
 	if err != nil {
@@ -1022,11 +1475,7 @@ func getLatestStoredVersion(chartName string) (repo.ChartVersion, error) {
 	return latestVersion, nil
 }
 
-func getByAnnotation(annotation, value string) map[string]repo.ChartVersions {
-	indexYaml, err := readIndex()
-	if err != nil {
-		logrus.Fatal(err)
-	}
+func getByAnnotation(indexYaml *repo.IndexFile, annotation, value string) map[string]repo.ChartVersions {
 	matchedVersions := make(map[string]repo.ChartVersions)
 
 	for chartName := range indexYaml.Entries {
@@ -1055,40 +1504,6 @@ func getByAnnotation(annotation, value string) map[string]repo.ChartVersions {
 	return matchedVersions
 }
 
-func removeVersionFromIndex(chartName string, version repo.ChartVersion) error {
-	entryIndex := -1
-	indexYaml, err := readIndex()
-	if err != nil {
-		return err
-	}
-	if _, ok := indexYaml.Entries[chartName]; !ok {
-		return fmt.Errorf("%s not present in index entries", chartName)
-	}
-
-	indexEntries := indexYaml.Entries[chartName]
-
-	for i, entryVersion := range indexEntries {
-		if entryVersion.Version == version.Version {
-			entryIndex = i
-			break
-		}
-	}
-
-	if entryIndex >= 0 {
-		entries := make(repo.ChartVersions, 0)
-		entries = append(entries, indexEntries[:entryIndex]...)
-		entries = append(entries, indexEntries[entryIndex+1:]...)
-		indexYaml.Entries[chartName] = entries
-	} else {
-		return fmt.Errorf("version %s not found for chart %s in index", version.Version, chartName)
-	}
-
-	indexFilePath := filepath.Join(getRepoRoot(), indexFile)
-	err = indexYaml.WriteFile(indexFilePath, 0644)
-
-	return err
-}
-
 // Reads in current index yaml
 func readIndex() (*repo.IndexFile, error) {
 	indexFilePath := filepath.Join(getRepoRoot(), indexFile)
@@ -1096,45 +1511,57 @@ func readIndex() (*repo.IndexFile, error) {
 	return helmIndexYaml, err
 }
 
-// Writes out modified index file
-func writeIndex() error {
-	indexFilePath := filepath.Join(getRepoRoot(), indexFile)
-	if _, err := os.Stat(indexFilePath); os.IsNotExist(err) {
-		err = repo.NewIndexFile().WriteFile(indexFilePath, 0644)
-		if err != nil {
-			return err
-		}
+// Loads the repository index for updating. Index shards are enabled if set
+// in configuration.yaml
+func loadIndex() (*index.Manager, error) {
+	configYaml, err := readConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	helmIndexYaml, err := repo.LoadIndexFile(indexFilePath)
+	indexManager, err := index.Load(
+		filepath.Join(getRepoRoot(), indexFile),
+		filepath.Join(getRepoRoot(), repositoryAssetsDir),
+		repositoryAssetsDir)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	assetsDirectoryPath := filepath.Join(getRepoRoot(), repositoryAssetsDir)
-	newHelmIndexYaml, err := repo.IndexDirectory(assetsDirectoryPath, repositoryAssetsDir)
-	if err != nil {
-		return err
+	if configYaml.ShardIndex {
+		indexManager.ShardsPath = filepath.Join(getRepoRoot(), repositoryIndexDir)
 	}
-	helmIndexYaml.Merge(newHelmIndexYaml)
-	helmIndexYaml.SortEntries()
-	err = helmIndexYaml.WriteFile(indexFilePath, 0644)
-	if err != nil {
-		return err
+
+	if keyPath := os.Getenv(signingKeyEnvVariable); keyPath != "" {
+		indexManager.Signer, err = sign.LoadSigner(keyPath, os.Getenv(signingPassphraseEnvVariable))
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return indexManager, nil
 }
 
 // Fetches metadata from upstream repositories.
 // Return list of skipped packages
-func fetchUpstreams(packageList PackageList) []string {
+func fetchUpstreams(packageList PackageList, indexManager *index.Manager) []string {
 	skippedList := make([]string, 0)
-	for _, packageWrapper := range packageList {
-		err := conformPackage(packageWrapper)
+	configYaml, err := readConfig()
+	if err != nil {
+		logrus.Error(err)
+	}
+	if configYaml.ScanThreshold != "" && !scan.ValidSeverity(configYaml.ScanThreshold) {
+		logrus.Fatalf("Invalid ScanThreshold '%s' in %s\n", configYaml.ScanThreshold, configOptionsFile)
+	}
+	kubeSchemas, err := loadKubeSchemas(configYaml)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	for i := range packageList {
+		packageWrapper := &packageList[i]
+		err := conformPackage(packageWrapper, indexManager, kubeSchemas, configYaml)
 		if err != nil {
 			logrus.Error(err)
-			skippedList = append(skippedList, packageWrapper.Name)
+			skippedList = append(skippedList, fmt.Sprintf("%s (%s)", packageWrapper.Name, err))
 			continue
 		}
 	}
@@ -1142,6 +1569,68 @@ func fetchUpstreams(packageList PackageList) []string {
 	return skippedList
 }
 
+// Reads configuration.yaml from the repository root. Returns an empty
+// configuration if the file does not exist
+func readConfig() (validate.ConfigurationYaml, error) {
+	configYamlPath := path.Join(getRepoRoot(), configOptionsFile)
+	if _, err := os.Stat(configYamlPath); os.IsNotExist(err) {
+		return validate.ConfigurationYaml{}, nil
+	}
+
+	configYaml, err := validate.ReadConfig(configYamlPath)
+	if err != nil {
+		return validate.ConfigurationYaml{}, err
+	}
+
+	configYaml.AnnotationPolicy.Managed = managedAnnotations
+
+	err = conform.SetDefaultExtractOptions(configYaml.Extract)
+	if err != nil {
+		return validate.ConfigurationYaml{}, fmt.Errorf("invalid Extract options in %s: %w", configOptionsFile, err)
+	}
+
+	return configYaml, nil
+}
+
+// Loads the Kubernetes schemas configured in configuration.yaml. Returns nil
+// if none are configured, so that only the known API deprecations are checked
+func loadKubeSchemas(configYaml validate.ConfigurationYaml) (*lint.KubeSchemas, error) {
+	if configYaml.KubeSchemas == "" {
+		return nil, nil
+	}
+
+	schemaPath := configYaml.KubeSchemas
+	if !filepath.IsAbs(schemaPath) {
+		schemaPath = filepath.Join(getRepoRoot(), schemaPath)
+	}
+	kubeSchemas, err := lint.LoadKubeSchemas(schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KubeSchemas in %s: %w", configOptionsFile, err)
+	}
+
+	return kubeSchemas, nil
+}
+
+// Logs the Kubernetes API findings of a chart version. Returns an error if
+// any use a removed API or are otherwise not served by the supported
+// Kubernetes versions, while deprecations are only warned about
+func checkKubeAPIs(helmChart *chart.Chart, kubeSchemas *lint.KubeSchemas) error {
+	apiErrors := 0
+	for _, finding := range lint.KubeAPIs(helmChart, kubeSchemas) {
+		if finding.Severity == lint.SeverityError {
+			logrus.Errorf("%s (%s): %s\n", helmChart.Name(), helmChart.Metadata.Version, finding.Message)
+			apiErrors++
+		} else {
+			logrus.Warnf("%s (%s): %s\n", helmChart.Name(), helmChart.Metadata.Version, finding.Message)
+		}
+	}
+	if apiErrors > 0 {
+		return fmt.Errorf("%s (%s) has %d Kubernetes API errors", helmChart.Name(), helmChart.Metadata.Version, apiErrors)
+	}
+
+	return nil
+}
+
 // Reads in upstream yaml file
 func parseUpstream(packagePath string) (*parse.UpstreamYaml, error) {
 	upstreamYaml, err := parse.ParseUpstreamYaml(packagePath)
@@ -1249,15 +1738,37 @@ func generateChanges(auto bool, stage bool) {
 	}
 
 	if len(packageList) > 0 {
-		skippedList := fetchUpstreams(packageList)
+		//The index is only loaded when charts are saved
+		var indexManager *index.Manager
+		if auto || stage {
+			indexManager, err = loadIndex()
+			if err != nil {
+				logrus.Fatal(err)
+			}
+		}
+		skippedList := fetchUpstreams(packageList, indexManager)
+		for _, packageWrapper := range packageList {
+			if len(packageWrapper.UpgradedVersions) > 0 {
+				logrus.Infof("Upgraded to chart apiVersion %s: %s/%s %v\n", chart.APIVersionV2,
+					packageWrapper.ParsedVendor, packageWrapper.Name, packageWrapper.UpgradedVersions)
+			}
+		}
 		if len(skippedList) > 0 {
-			logrus.Errorf("Skipped due to error: %v", skippedList)
+			logrus.Errorf("Skipped due to error: %s", strings.Join(skippedList, ", "))
 		}
 		if len(skippedList) >= len(packageList) {
 			logrus.Fatalf("All packages skipped. Exiting...")
 		}
 		if auto || stage {
-			err = writeIndex()
+			for _, packageWrapper := range packageList {
+				for _, assetFile := range packageWrapper.SavedAssets {
+					err = indexManager.Add(assetFile)
+					if err != nil {
+						logrus.Error(err)
+					}
+				}
+			}
+			err = indexManager.Write()
 			if err != nil {
 				logrus.Error(err)
 			}
@@ -1330,14 +1841,23 @@ func addFeaturedChart(c *cli.Context) {
 		logrus.Fatal(err)
 	}
 
-	featuredVersions := getByAnnotation(annotationFeatured, c.Args().Get(1))
+	indexManager, err := loadIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	featuredVersions := getByAnnotation(indexManager.IndexFile, annotationFeatured, c.Args().Get(1))
 
 	if len(featuredVersions) > 0 {
 		for chartName := range featuredVersions {
 			logrus.Errorf("%s already featured at index %d\n", chartName, featuredNumber)
 		}
 	} else {
-		err = packageList[0].annotate(annotationFeatured, c.Args().Get(1), false, true)
+		err = packageList[0].annotate(indexManager, annotationFeatured, c.Args().Get(1), false, true)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		err = indexManager.Write()
 		if err != nil {
 			logrus.Fatal(err)
 		}
@@ -1363,7 +1883,17 @@ func removeFeaturedChart(c *cli.Context) {
 		logrus.Fatal(err)
 	}
 
-	err = packageList[0].annotate(annotationFeatured, "", true, false)
+	indexManager, err := loadIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	err = packageList[0].annotate(indexManager, annotationFeatured, "", true, false)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	err = indexManager.Write()
 	if err != nil {
 		logrus.Fatal(err)
 	}
@@ -1372,7 +1902,11 @@ func removeFeaturedChart(c *cli.Context) {
 func listFeaturedCharts(c *cli.Context) {
 	indexConflict := false
 	featuredSorted := make([]string, featuredMax)
-	featuredVersions := getByAnnotation(annotationFeatured, "")
+	indexYaml, err := readIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	featuredVersions := getByAnnotation(indexYaml, annotationFeatured, "")
 
 	for chartName, chartVersion := range featuredVersions {
 		featuredIndex, err := strconv.Atoi(chartVersion[0].Annotations[annotationFeatured])
@@ -1404,6 +1938,10 @@ func hideChart(c *cli.Context) {
 	if len(c.Args()) < 1 {
 		logrus.Fatal("Provide package name(s) as argument")
 	}
+	indexManager, err := loadIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
 	for _, currentPackage := range c.Args() {
 		packageList, err := populatePackages(currentPackage, false, false, false)
 		if err != nil {
@@ -1411,12 +1949,17 @@ func hideChart(c *cli.Context) {
 		}
 
 		if len(packageList) == 1 {
-			err = packageList[0].annotate(annotationHidden, "true", false, false)
+			err = packageList[0].annotate(indexManager, annotationHidden, "true", false, false)
 			if err != nil {
 				logrus.Error(err)
 			}
 		}
 	}
+
+	err = indexManager.Write()
+	if err != nil {
+		logrus.Fatal(err)
+	}
 }
 
 // CLI function call - Cleans package object(s)
@@ -1454,104 +1997,827 @@ func autoUpdate(c *cli.Context) {
 	generateChanges(true, false)
 }
 
-// CLI function call - Validates repo against released
-func validateRepo(c *cli.Context) {
-	validatePaths := map[string]validate.DirectoryComparison{
-		"assets": {},
+// Returns the name the charts of a package are stored under. This is the name
+// set in ChartMetadata, or otherwise the upstream chart name. Upstream metadata
+// is only fetched if neither the Helm chart, the Artifact Hub package, nor the
+// package directory name is the name of a stored chart of the vendor
+func packageChartName(packagePath string, upstreamYaml parse.UpstreamYaml, storedCharts map[string]bool) string {
+	if upstreamYaml.ChartYaml.Name != "" {
+		return upstreamYaml.ChartYaml.Name
+	}
+	if upstreamYaml.HelmChart != "" {
+		return upstreamYaml.HelmChart
 	}
 
-	excludeFiles := make(map[string]struct{})
-	var exclude = struct{}{}
-	excludeFiles["README.md"] = exclude
+	candidates := []string{upstreamYaml.AHPackageName, filepath.Base(packagePath)}
+	for _, chartName := range candidates {
+		if chartName == "" {
+			continue
+		}
+		_, parsedVendor := parseVendor(upstreamYaml.Vendor, chartName, packagePath)
+		if storedCharts[path.Join(parsedVendor, chartName)] {
+			return chartName
+		}
+	}
 
-	directoryComparison := validate.DirectoryComparison{}
+	sourceMetadata, err := generateChartSourceMetadata(upstreamYaml)
+	if err != nil || len(sourceMetadata.Versions) == 0 {
+		logrus.Warnf("Unable to read the chart name of %s from upstream: %v\n", getRelativePath(packagePath), err)
+		return filepath.Base(packagePath)
+	}
 
-	configYamlPath := path.Join(getRepoRoot(), configOptionsFile)
-	if _, err := os.Stat(configYamlPath); os.IsNotExist(err) {
-		logrus.Fatalf("Unable to read %s\n", configOptionsFile)
+	return sourceMetadata.Versions[0].Name
+}
+
+// Reads the upstream options of each package. Packages are keyed by
+// <vendor>/<chart>, where the chart name is the name the charts of the package
+// are stored under, as listed in storedCharts by <vendor>/<chart>. Packages
+// using package.yaml are keyed by their directory name and have empty
+// upstream options
+func readPackageUpstreams(currentPackage string, storedCharts map[string]bool) map[string]parse.UpstreamYaml {
+	upstreams := make(map[string]parse.UpstreamYaml)
+	for _, packageWrapper := range generatePackageList(currentPackage) {
+		chartName := filepath.Base(packageWrapper.Path)
+		upstreamYaml := parse.UpstreamYaml{}
+		if !packageWrapper.ManualUpdate {
+			parsedUpstream, err := parseUpstream(packageWrapper.Path)
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+			upstreamYaml = *parsedUpstream
+			chartName = packageChartName(packageWrapper.Path, upstreamYaml, storedCharts)
+		}
+		_, parsedVendor := parseVendor(upstreamYaml.Vendor, chartName, packageWrapper.Path)
+		upstreams[path.Join(parsedVendor, chartName)] = upstreamYaml
 	}
-	configYaml, err := validate.ReadConfig(configYamlPath)
-	if err != nil {
-		logrus.Fatal(err)
+
+	return upstreams
+}
+
+// Returns the <vendor>/<chart> of each chart in the index
+func indexedCharts(indexYaml *repo.IndexFile) map[string]bool {
+	storedCharts := make(map[string]bool)
+	for chartName, chartVersions := range indexYaml.Entries {
+		if len(chartVersions) > 0 {
+			storedCharts[path.Join(index.AssetVendor(chartVersions[0], repositoryAssetsDir), chartName)] = true
+		}
 	}
 
-	if len(configYaml.Validate) == 0 || configYaml.Validate[0].Branch == "" || configYaml.Validate[0].Url == "" {
-		logrus.Fatal("Invalid validation configuration")
+	return storedCharts
+}
+
+// Returns the upstream options of the package a stored chart is generated
+// from. CRD charts are matched to the package of their main chart
+func lookupPackageUpstream(packageUpstreams map[string]parse.UpstreamYaml, vendor, chartName string) (parse.UpstreamYaml, bool) {
+	upstreamYaml, ok := packageUpstreams[path.Join(vendor, chartName)]
+	if !ok {
+		upstreamYaml, ok = packageUpstreams[path.Join(vendor, strings.TrimSuffix(chartName, conform.CRDChartSuffix))]
 	}
 
-	cloneDir, err := os.MkdirTemp("", "gitRepo")
+	return upstreamYaml, ok
+}
+
+// CLI function call - Removes chart versions not kept by the retention policy
+// from the index and assets, then commits the changes. Featured versions and
+// the latest version of each chart are never removed
+func pruneCharts(c *cli.Context) {
+	currentPackage := os.Getenv(packageEnvVariable)
+	configYaml, err := readConfig()
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	err = validate.CloneRepo(configYaml.Validate[0].Url, configYaml.Validate[0].Branch, cloneDir)
+	indexManager, err := loadIndex()
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	for dirPath := range validatePaths {
-		upstreamPath := path.Join(cloneDir, dirPath)
-		updatePath := path.Join(getRepoRoot(), dirPath)
-		if _, err := os.Stat(updatePath); os.IsNotExist(err) {
-			logrus.Infof("Directory '%s' not in source. Skipping...", dirPath)
+	packageUpstreams := readPackageUpstreams(currentPackage, indexedCharts(indexManager.IndexFile))
+
+	chartNames := make([]string, 0, len(indexManager.IndexFile.Entries))
+	for chartName := range indexManager.IndexFile.Entries {
+		chartNames = append(chartNames, chartName)
+	}
+	sort.Strings(chartNames)
+
+	prunedVersions := make(map[string][]string)
+	prunedPaths := make([]string, 0)
+	for _, chartName := range chartNames {
+		chartVersions := indexManager.IndexFile.Entries[chartName]
+		vendor := index.AssetVendor(chartVersions[0], repositoryAssetsDir)
+		upstreamYaml, ok := lookupPackageUpstream(packageUpstreams, vendor, chartName)
+		if currentPackage != "" && !ok {
 			continue
 		}
-		if _, err := os.Stat(upstreamPath); os.IsNotExist(err) {
-			logrus.Infof("Directory '%s' not in upstream. Skipping...", dirPath)
-			continue
+		if !ok {
+			logrus.Warnf("No package found for %s/%s. Using the retention policy in %s\n", vendor, chartName, configOptionsFile)
 		}
-		newComparison, err := validate.CompareDirectories(upstreamPath, updatePath, excludeFiles)
+		retention := upstreamYaml.Retention
+		if retention.IsEmpty() {
+			retention = configYaml.Retention
+		}
+
+		prunable, err := index.PrunableVersions(chartVersions, retention, upstreamYaml.TrackVersions)
 		if err != nil {
-			logrus.Error(err)
+			logrus.Errorf("%s: %s\n", chartName, err)
+			continue
 		}
-		directoryComparison.Merge(newComparison)
-		validatePaths[dirPath] = newComparison
-	}
 
-	err = os.RemoveAll(cloneDir)
-	if err != nil {
-		logrus.Error(err)
-	}
+		for _, chartVersion := range prunable {
+			if _, ok := chartVersion.Annotations[annotationFeatured]; ok {
+				logrus.Infof("Keeping featured version %s (%s)\n", chartName, chartVersion.Version)
+				continue
+			}
+			logrus.Infof("Pruning %s (%s)\n", chartName, chartVersion.Version)
+			chartKey := path.Join(vendor, chartName)
+			prunedVersions[chartKey] = append(prunedVersions[chartKey], chartVersion.Version)
+			if c.Bool("dry-run") {
+				continue
+			}
 
-	if len(directoryComparison.Added) > 0 {
-		outString := ""
-		for dirPath := range validatePaths {
-			if len(validatePaths[dirPath].Added) > 0 {
-				outString += fmt.Sprintf("\n - %s", dirPath)
-				stringJoiner := fmt.Sprintf("\n - %s", dirPath)
-				fileList := strings.Join(validatePaths[dirPath].Added[:], stringJoiner)
-				outString += fileList
+			if len(chartVersion.URLs) > 0 {
+				err = os.Remove(filepath.Join(getRepoRoot(), filepath.FromSlash(chartVersion.URLs[0])))
+				if err != nil && !os.IsNotExist(err) {
+					logrus.Fatal(err)
+				}
+				prunedPaths = append(prunedPaths, chartVersion.URLs[0])
+			}
+			err = indexManager.Remove(chartName, chartVersion.Version)
+			if err != nil {
+				logrus.Fatal(err)
 			}
 		}
-		logrus.Infof("Files Added:%s", outString)
 	}
 
-	if len(directoryComparison.Removed) > 0 {
-		outString := ""
-		for dirPath := range validatePaths {
-			if len(validatePaths[dirPath].Removed) > 0 {
-				outString += fmt.Sprintf("\n - %s", dirPath)
-				stringJoiner := fmt.Sprintf("\n - %s", dirPath)
-				fileList := strings.Join(validatePaths[dirPath].Removed[:], stringJoiner)
-				outString += fileList
-			}
-		}
-		logrus.Warnf("Files Removed:%s", outString)
+	if len(prunedVersions) == 0 {
+		logrus.Info("No versions to prune")
+		return
+	}
+	if c.Bool("dry-run") {
+		return
 	}
 
-	if len(directoryComparison.Modified) > 0 {
-		outString := ""
-		for dirPath := range validatePaths {
-			if len(validatePaths[dirPath].Modified) > 0 {
-				outString += fmt.Sprintf("\n - %s", dirPath)
-				stringJoiner := fmt.Sprintf("\n - %s", dirPath)
-				fileList := strings.Join(validatePaths[dirPath].Modified[:], stringJoiner)
-				outString += fileList
+	err = indexManager.Write()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	err = commitIndexChanges("Removed", prunedVersions, prunedPaths)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// Looks up the index entries of a chart given as <vendor>/<chart>
+func lookupStoredChart(indexManager *index.Manager, vendorChart string) (string, string, repo.ChartVersions, error) {
+	vendor, chartName, ok := strings.Cut(strings.Trim(vendorChart, "/"), "/")
+	if !ok || vendor == "" || chartName == "" || strings.Contains(chartName, "/") {
+		return "", "", nil, fmt.Errorf("chart '%s' must be given as <vendor>/<chart>", vendorChart)
+	}
+
+	chartVersions, ok := indexManager.IndexFile.Entries[chartName]
+	if !ok || len(chartVersions) == 0 {
+		return "", "", nil, fmt.Errorf("%s not present in index entries", chartName)
+	}
+	if index.AssetVendor(chartVersions[0], repositoryAssetsDir) != vendor {
+		return "", "", nil, fmt.Errorf("%s is not stored under vendor %s", chartName, vendor)
+	}
+
+	return vendor, chartName, chartVersions, nil
+}
+
+// Extracts the latest indexed version of a chart into its chart directory, or
+// removes the directory if no versions remain. Returns the directory relative
+// to the repository root
+func refreshChartDirectory(indexManager *index.Manager, vendor, chartName string) (string, error) {
+	chartsPath := path.Join(repositoryChartsDir, vendor, chartName)
+	chartPath := filepath.Join(getRepoRoot(), filepath.FromSlash(chartsPath))
+	err := os.RemoveAll(chartPath)
+	if err != nil {
+		return "", err
+	}
+
+	if len(indexManager.IndexFile.Entries[chartName]) == 0 {
+		logrus.Infof("Removed %s\n", chartsPath)
+		return chartsPath, nil
+	}
+
+	indexManager.IndexFile.SortEntries()
+	latestVersion := indexManager.IndexFile.Entries[chartName][0]
+	assetFile := filepath.Join(getRepoRoot(), filepath.FromSlash(latestVersion.URLs[0]))
+	helmChart, err := loader.LoadFile(assetFile)
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Debugf("Extracting %s to %s\n", latestVersion.URLs[0], chartsPath)
+	err = exportChartDirectory(helmChart, assetFile, chartPath)
+
+	return chartsPath, err
+}
+
+// Returns the name of a stored chart followed by the name of its companion CRD
+// chart, if one is stored under the same vendor
+func storedChartNames(indexManager *index.Manager, vendor, chartName string) []string {
+	chartNames := []string{chartName}
+	crdChartName := chartName + conform.CRDChartSuffix
+	if crdVersions := indexManager.IndexFile.Entries[crdChartName]; len(crdVersions) > 0 && index.AssetVendor(crdVersions[0], repositoryAssetsDir) == vendor {
+		chartNames = append(chartNames, crdChartName)
+	}
+
+	return chartNames
+}
+
+// Returns the index entry of a chart version, or nil if not found
+func findChartVersion(chartVersions repo.ChartVersions, version string) *repo.ChartVersion {
+	for _, chartVersion := range chartVersions {
+		if chartVersion.Version == version {
+			return chartVersion
+		}
+	}
+
+	return nil
+}
+
+// CLI function call - Removes a released chart version from the index, assets,
+// and charts directory, then commits the changes. The same version of the
+// companion CRD chart is also removed
+func removeChartVersion(c *cli.Context) {
+	if len(c.Args()) != 2 {
+		logrus.Fatal("Please provide the chart as <vendor>/<chart> and the version to remove as arguments")
+	}
+	indexManager, err := loadIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	vendor, chartName, chartVersions, err := lookupStoredChart(indexManager, c.Args().Get(0))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	version := c.Args().Get(1)
+	if findChartVersion(chartVersions, version) == nil {
+		logrus.Fatalf("version %s not found for chart %s in index\n", version, chartName)
+	}
+
+	removedVersions := make(map[string][]string)
+	changedPaths := make([]string, 0)
+	for _, storedChartName := range storedChartNames(indexManager, vendor, chartName) {
+		removedVersion := findChartVersion(indexManager.IndexFile.Entries[storedChartName], version)
+		if removedVersion == nil {
+			continue
+		}
+		if featured, ok := removedVersion.Annotations[annotationFeatured]; ok {
+			logrus.Warnf("Removing %s (%s) featured at index %s\n", storedChartName, removedVersion.Version, featured)
+		}
+
+		if len(removedVersion.URLs) > 0 {
+			err = os.Remove(filepath.Join(getRepoRoot(), filepath.FromSlash(removedVersion.URLs[0])))
+			if err != nil && !os.IsNotExist(err) {
+				logrus.Fatal(err)
+			}
+			changedPaths = append(changedPaths, removedVersion.URLs[0])
+		}
+
+		err = indexManager.Remove(storedChartName, removedVersion.Version)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		chartsPath, err := refreshChartDirectory(indexManager, vendor, storedChartName)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		changedPaths = append(changedPaths, chartsPath)
+
+		logrus.Infof("Removed %s/%s (%s)\n", vendor, storedChartName, removedVersion.Version)
+		removedVersions[path.Join(vendor, storedChartName)] = []string{removedVersion.Version}
+	}
+
+	err = indexManager.Write()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	err = commitIndexChanges("Removed", removedVersions, changedPaths)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// CLI function call - Marks a released chart version, or all versions if none
+// is given, as deprecated in Chart.yaml, then commits the changes. The same
+// versions of the companion CRD chart are also deprecated
+func deprecateChart(c *cli.Context) {
+	if len(c.Args()) < 1 || len(c.Args()) > 2 {
+		logrus.Fatal("Please provide the chart as <vendor>/<chart> and optionally the version to deprecate as arguments")
+	}
+	indexManager, err := loadIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	vendor, chartName, chartVersions, err := lookupStoredChart(indexManager, c.Args().Get(0))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	version := c.Args().Get(1)
+	if version != "" && findChartVersion(chartVersions, version) == nil {
+		logrus.Fatalf("version %s not found for chart %s in index\n", version, chartName)
+	}
+
+	deprecatedVersions := make(map[string][]string)
+	changedPaths := make([]string, 0)
+	for _, storedChartName := range storedChartNames(indexManager, vendor, chartName) {
+		versionsToUpdate := make(repo.ChartVersions, 0)
+		for _, chartVersion := range indexManager.IndexFile.Entries[storedChartName] {
+			if version == "" || chartVersion.Version == version {
+				versionsToUpdate = append(versionsToUpdate, chartVersion)
+			}
+		}
+
+		deprecated := make([]string, 0)
+		for _, chartVersion := range versionsToUpdate {
+			if chartVersion.Deprecated {
+				logrus.Infof("%s (%s) is already deprecated\n", storedChartName, chartVersion.Version)
+				continue
+			}
+
+			assetFile := filepath.Join(getRepoRoot(), filepath.FromSlash(chartVersion.URLs[0]))
+			helmChart, err := loader.LoadFile(assetFile)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			helmChart.Metadata.Deprecated = true
+
+			err = conform.ExportChartAsset(helmChart, filepath.Dir(assetFile))
+			if err != nil {
+				logrus.Fatal(err)
+			}
+			err = indexManager.Add(assetFile)
+			if err != nil {
+				logrus.Fatal(err)
+			}
+
+			logrus.Infof("Deprecated %s/%s (%s)\n", vendor, storedChartName, chartVersion.Version)
+			deprecated = append(deprecated, chartVersion.Version)
+			changedPaths = append(changedPaths, chartVersion.URLs[0])
+		}
+
+		if len(deprecated) == 0 {
+			continue
+		}
+
+		chartsPath, err := refreshChartDirectory(indexManager, vendor, storedChartName)
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		changedPaths = append(changedPaths, chartsPath)
+		deprecatedVersions[path.Join(vendor, storedChartName)] = deprecated
+	}
+
+	if len(deprecatedVersions) == 0 {
+		return
+	}
+
+	err = indexManager.Write()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	err = commitIndexChanges("Deprecated", deprecatedVersions, changedPaths)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// CLI function call - Prints the chart and version changes to index.yaml
+// between two git revisions
+func diffIndex(c *cli.Context) {
+	if len(c.Args()) != 2 {
+		logrus.Fatal("Please provide the two git revisions to compare as arguments")
+	}
+
+	oldIndex, err := index.ReadRevision(getRepoRoot(), c.Args().Get(0), indexFile)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	newIndex, err := index.ReadRevision(getRepoRoot(), c.Args().Get(1), indexFile)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	out, err := index.Diff(oldIndex, newIndex).Format(c.String("output"))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	fmt.Print(out)
+}
+
+// CLI function call - Pushes released chart versions missing from an OCI
+// registry. Optionally limited to a vendor or <vendor>/<chart> by PACKAGE
+func publishOCI(c *cli.Context) {
+	if len(c.Args()) != 1 {
+		logrus.Fatal("Please provide the OCI repository to publish to as an argument, such as oci://registry.example.com/charts")
+	}
+	currentPackage := strings.Trim(os.Getenv(packageEnvVariable), "/")
+
+	publisher, err := oci.NewPublisher(c.Args().Get(0))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	indexYaml, err := readIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	indexYaml.SortEntries()
+
+	chartNames := make([]string, 0, len(indexYaml.Entries))
+	for chartName := range indexYaml.Entries {
+		chartNames = append(chartNames, chartName)
+	}
+	sort.Strings(chartNames)
+
+	publishErrors := false
+	for _, chartName := range chartNames {
+		chartVersions := indexYaml.Entries[chartName]
+		vendor := index.AssetVendor(chartVersions[0], repositoryAssetsDir)
+		if currentPackage != "" && currentPackage != vendor && currentPackage != path.Join(vendor, chartName) {
+			continue
+		}
+
+		missing, err := publisher.MissingVersions(chartName, chartVersions)
+		if err != nil {
+			logrus.Errorf("%s: %s\n", chartName, err)
+			publishErrors = true
+			continue
+		}
+		logrus.Debugf("%s: %d of %d versions already published\n", chartName, len(chartVersions)-len(missing), len(chartVersions))
+
+		for _, chartVersion := range missing {
+			if len(chartVersion.URLs) == 0 || strings.Contains(chartVersion.URLs[0], "://") {
+				logrus.Warnf("Skipping %s (%s). No local asset\n", chartName, chartVersion.Version)
+				continue
+			}
+			if c.Bool("dry-run") {
+				logrus.Infof("Would push %s (%s)\n", chartName, chartVersion.Version)
+				continue
+			}
+
+			ref, err := publisher.Push(filepath.Join(getRepoRoot(), filepath.FromSlash(chartVersion.URLs[0])))
+			if err != nil {
+				logrus.Error(err)
+				publishErrors = true
+				continue
+			}
+			logrus.Infof("Pushed %s\n", ref)
+		}
+	}
+
+	if publishErrors {
+		logrus.Fatal("Unable to publish all chart versions")
+	}
+}
+
+// CLI function call - Writes the HTML and JSON chart catalog generated from
+// the index and stored charts
+func generateReport(c *cli.Context) {
+	indexYaml, err := readIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	packageUpstreams := readPackageUpstreams("", indexedCharts(indexYaml))
+	sources := make(map[string]*report.Source)
+	for chartName, chartVersions := range indexYaml.Entries {
+		vendor := index.AssetVendor(chartVersions[0], repositoryAssetsDir)
+		if upstreamYaml, ok := lookupPackageUpstream(packageUpstreams, vendor, chartName); ok {
+			sources[path.Join(vendor, chartName)] = report.UpstreamSource(upstreamYaml)
+		}
+	}
+
+	catalog, err := report.Generate(indexYaml, repositoryAssetsDir, filepath.Join(getRepoRoot(), repositoryChartsDir), sources)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	outputPath := c.String("output")
+	if !filepath.IsAbs(outputPath) {
+		outputPath = filepath.Join(getRepoRoot(), outputPath)
+	}
+	err = catalog.Write(outputPath)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// CLI function call - Verifies the signatures of the index and asset
+// checksums, and the checksums of the assets, against a public key
+func verifyRepo(c *cli.Context) {
+	keyPath := c.String("key")
+	if keyPath == "" {
+		logrus.Fatal("Please provide the public key to verify against with --key")
+	}
+
+	verifier, err := sign.LoadVerifier(keyPath)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	verifyErrors := false
+	assetsPath := filepath.Join(getRepoRoot(), repositoryAssetsDir)
+	for _, filePath := range []string{indexFile, path.Join(repositoryAssetsDir, sign.ChecksumsFile)} {
+		err = sign.VerifyFile(verifier, filepath.Join(getRepoRoot(), filePath))
+		if err != nil {
+			logrus.Error(err)
+			verifyErrors = true
+			continue
+		}
+		logrus.Infof("Verified signature of %s\n", filePath)
+	}
+
+	mismatches, err := sign.VerifyChecksums(assetsPath)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	for _, mismatch := range mismatches {
+		logrus.Error(mismatch)
+		verifyErrors = true
+	}
+
+	if verifyErrors {
+		logrus.Fatal("Verification failed")
+	}
+	logrus.Info("Index and assets verified")
+}
+
+// Lists Chart.yaml paths of stored charts, optionally limited to a vendor or <vendor>/<chart>
+func listStoredCharts(currentPackage string) ([]string, error) {
+	currentPackage = strings.Trim(filepath.ToSlash(currentPackage), "/")
+	searchPattern := filepath.Join(getRepoRoot(), repositoryChartsDir, "*", "*", "Chart.yaml")
+	if currentPackage != "" {
+		searchPattern = filepath.Join(getRepoRoot(), repositoryChartsDir, currentPackage)
+		for i := len(strings.Split(currentPackage, "/")); i < 2; i++ {
+			searchPattern = filepath.Join(searchPattern, "*")
+		}
+		searchPattern = filepath.Join(searchPattern, "Chart.yaml")
+	}
+
+	chartFiles, err := filepath.Glob(searchPattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(chartFiles)
+
+	return chartFiles, nil
+}
+
+// CLI function call - Reports issues found in stored charts
+func lintCharts(c *cli.Context) {
+	lintErrors := false
+	configYaml, err := readConfig()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	kubeSchemas, err := loadKubeSchemas(configYaml)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	chartsPath := filepath.Join(getRepoRoot(), repositoryChartsDir)
+	chartFiles, err := listStoredCharts(os.Getenv(packageEnvVariable))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	for _, chartFile := range chartFiles {
+		chartPath := filepath.Dir(chartFile)
+		chartName := strings.TrimPrefix(strings.TrimPrefix(chartPath, chartsPath), "/")
+		helmChart, err := loader.Load(chartPath)
+		if err != nil {
+			logrus.Errorf("%s: %s", chartName, err)
+			lintErrors = true
+			continue
+		}
+
+		findings := append(lint.Chart(helmChart), lint.KubeAPIs(helmChart, kubeSchemas)...)
+		findings = append(findings, lint.Icon(helmChart, getRepoRoot(), configYaml.Icons.MaxSize)...)
+		for _, finding := range findings {
+			if finding.Severity == lint.SeverityError {
+				logrus.Errorf("%s (%s): %s", chartName, helmChart.Metadata.Version, finding.Message)
+				lintErrors = true
+			} else {
+				logrus.Warnf("%s (%s): %s", chartName, helmChart.Metadata.Version, finding.Message)
+			}
+		}
+	}
+
+	if lintErrors {
+		logrus.Fatal("Lint errors found")
+	}
+}
+
+// CLI function call - Cross-validates index, assets, and chart directories.
+// If --fix is set, every asset is re-indexed as by a full index rebuild, then
+// the remaining issues are fixed from assets. Orphaned chart directories are
+// only removed if --remove-orphans is also set
+func checkRepo(c *cli.Context) {
+	fix := c.Bool("fix")
+	if c.Bool("remove-orphans") && !fix {
+		logrus.Fatal("--remove-orphans requires --fix")
+	}
+
+	indexManager, err := loadIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	if fix {
+		logrus.Info("Rebuilding index from assets")
+		err = indexManager.Rebuild()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	chartsPath := filepath.Join(getRepoRoot(), repositoryChartsDir)
+	issues, err := indexManager.Check(chartsPath)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	for _, issue := range issues {
+		logrus.Error(issue)
+	}
+
+	if !fix {
+		if len(issues) > 0 {
+			logrus.Fatalf("%d consistency issues found. Run with --fix to rebuild from assets\n", len(issues))
+		}
+		logrus.Info("Index, assets, and charts are consistent")
+		return
+	}
+
+	err = indexManager.Fix(chartsPath, issues, c.Bool("remove-orphans"))
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	err = indexManager.Write()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	issues, err = indexManager.Check(chartsPath)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	if len(issues) > 0 {
+		for _, issue := range issues {
+			logrus.Error(issue)
+		}
+		logrus.Fatalf("%d consistency issues remain after fix. Orphaned chart directories are removed with --remove-orphans\n", len(issues))
+	}
+	logrus.Info("Index and charts rebuilt from assets")
+}
+
+// Logs the annotation policy violations of indexed charts. Released charts
+// cannot be altered, so only violations of added assets are errors. Returns
+// true if any added asset violates the policy
+func reportPolicyViolations(policyViolations []validate.PolicyViolation, addedAssets []string) bool {
+	added := make(map[string]bool)
+	for _, addedPath := range addedAssets {
+		added[path.Join(repositoryAssetsDir, addedPath)] = true
+	}
+	policyFailed := false
+	for _, policyViolation := range policyViolations {
+		for _, violation := range policyViolation.Violations {
+			if added[policyViolation.URL] {
+				logrus.Errorf("%s (%s): %s\n", policyViolation.Chart, policyViolation.Version, violation)
+				policyFailed = true
+			} else {
+				logrus.Warnf("%s (%s): %s\n", policyViolation.Chart, policyViolation.Version, violation)
+			}
+		}
+	}
+
+	return policyFailed
+}
+
+// CLI function call - Validates repo against released
+func validateRepo(c *cli.Context) {
+	validatePaths := map[string]validate.DirectoryComparison{
+		"assets": {},
+	}
+
+	excludeFiles := make(map[string]struct{})
+	var exclude = struct{}{}
+	excludeFiles["README.md"] = exclude
+	excludeFiles[sign.ChecksumsFile] = exclude
+	excludeFiles[sign.ChecksumsFile+sign.OpenPGPExtension] = exclude
+	excludeFiles[sign.ChecksumsFile+sign.Ed25519Extension] = exclude
+
+	directoryComparison := validate.DirectoryComparison{}
+
+	configYamlPath := path.Join(getRepoRoot(), configOptionsFile)
+	if _, err := os.Stat(configYamlPath); os.IsNotExist(err) {
+		logrus.Fatalf("Unable to read %s\n", configOptionsFile)
+	}
+	configYaml, err := readConfig()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	if len(configYaml.Validate) == 0 || configYaml.Validate[0].Branch == "" || configYaml.Validate[0].Url == "" {
+		logrus.Fatal("Invalid validation configuration")
+	}
+
+	helmIndexYaml, err := readIndex()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	policyViolations := configYaml.AnnotationPolicy.CheckIndex(helmIndexYaml)
+
+	cloneDir, err := os.MkdirTemp("", "gitRepo")
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	err = validate.CloneRepo(configYaml.Validate[0].Url, configYaml.Validate[0].Branch, cloneDir)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	for dirPath := range validatePaths {
+		upstreamPath := path.Join(cloneDir, dirPath)
+		updatePath := path.Join(getRepoRoot(), dirPath)
+		if _, err := os.Stat(updatePath); os.IsNotExist(err) {
+			logrus.Infof("Directory '%s' not in source. Skipping...", dirPath)
+			continue
+		}
+		if _, err := os.Stat(upstreamPath); os.IsNotExist(err) {
+			logrus.Infof("Directory '%s' not in upstream. Skipping...", dirPath)
+			continue
+		}
+		newComparison, err := validate.CompareDirectories(upstreamPath, updatePath, excludeFiles)
+		if err != nil {
+			logrus.Error(err)
+		}
+		directoryComparison.Merge(newComparison)
+		validatePaths[dirPath] = newComparison
+	}
+
+	err = os.RemoveAll(cloneDir)
+	if err != nil {
+		logrus.Error(err)
+	}
+
+	if len(directoryComparison.Added) > 0 {
+		outString := ""
+		for dirPath := range validatePaths {
+			if len(validatePaths[dirPath].Added) > 0 {
+				outString += fmt.Sprintf("\n - %s", dirPath)
+				stringJoiner := fmt.Sprintf("\n - %s", dirPath)
+				fileList := strings.Join(validatePaths[dirPath].Added[:], stringJoiner)
+				outString += fileList
+			}
+		}
+		logrus.Infof("Files Added:%s", outString)
+	}
+
+	if len(directoryComparison.Removed) > 0 {
+		outString := ""
+		for dirPath := range validatePaths {
+			if len(validatePaths[dirPath].Removed) > 0 {
+				outString += fmt.Sprintf("\n - %s", dirPath)
+				stringJoiner := fmt.Sprintf("\n - %s", dirPath)
+				fileList := strings.Join(validatePaths[dirPath].Removed[:], stringJoiner)
+				outString += fileList
+			}
+		}
+		logrus.Warnf("Files Removed:%s", outString)
+	}
+
+	policyFailed := reportPolicyViolations(policyViolations, validatePaths["assets"].Added)
+
+	if len(directoryComparison.Modified) > 0 {
+		outString := ""
+		for dirPath := range validatePaths {
+			if len(validatePaths[dirPath].Modified) > 0 {
+				outString += fmt.Sprintf("\n - %s", dirPath)
+				stringJoiner := fmt.Sprintf("\n - %s", dirPath)
+				fileList := strings.Join(validatePaths[dirPath].Modified[:], stringJoiner)
+				outString += fileList
 			}
 		}
 		logrus.Fatalf("Files Modified:%s", outString)
 	}
 
+	if policyFailed {
+		logrus.Fatal("Added charts violate annotation policy")
+	}
+
 	logrus.Infof("Successfully validated\n  Upstream: %s\n  Branch: %s\n",
 		configYaml.Validate[0].Url, configYaml.Validate[0].Branch)
 
@@ -1634,6 +2900,97 @@ func main() {
 			Usage:  "Check repo against released charts",
 			Action: validateRepo,
 		},
+		{
+			Name:   "lint",
+			Usage:  "Report issues found in stored charts",
+			Action: lintCharts,
+		},
+		{
+			Name:      "remove",
+			Usage:     "Remove a released chart version and commit",
+			ArgsUsage: "<vendor>/<chart> <version>",
+			Action:    removeChartVersion,
+		},
+		{
+			Name:      "deprecate",
+			Usage:     "Mark released chart versions as deprecated and commit",
+			ArgsUsage: "<vendor>/<chart> [version]",
+			Action:    deprecateChart,
+		},
+		{
+			Name:      "diff-index",
+			Usage:     "Print chart and version changes to the index between two git revisions",
+			ArgsUsage: "<revision> <revision>",
+			Action:    diffIndex,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Output format: text, markdown, or json",
+					Value: index.DiffText,
+				},
+			},
+		},
+		{
+			Name:      "publish-oci",
+			Usage:     "Push released chart versions missing from an OCI registry",
+			ArgsUsage: "<oci://registry/path>",
+			Action:    publishOCI,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "List versions to be pushed without pushing them",
+				},
+			},
+		},
+		{
+			Name:   "report",
+			Usage:  "Generate an HTML and JSON catalog of released charts",
+			Action: generateReport,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Usage: "Directory to write the catalog to, relative to the repository root",
+					Value: "report",
+				},
+			},
+		},
+		{
+			Name:   "verify",
+			Usage:  "Verify index and asset signatures against a public key",
+			Action: verifyRepo,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "key, k",
+					Usage: "Path to an armored OpenPGP or PEM encoded ed25519 public key",
+				},
+			},
+		},
+		{
+			Name:   "prune",
+			Usage:  "Remove chart versions not kept by the retention policy and commit",
+			Action: pruneCharts,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "List versions to be removed without removing them",
+				},
+			},
+		},
+		{
+			Name:   "check",
+			Usage:  "Cross-validate index, assets, and stored charts",
+			Action: checkRepo,
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "fix",
+					Usage: "Re-index every asset as a full index rebuild, then rebuild index entries and chart directories from assets",
+				},
+				cli.BoolFlag{
+					Name:  "remove-orphans",
+					Usage: "With --fix, remove chart directories that have no assets",
+				},
+			},
+		},
 	}
 
 	err := app.Run(os.Args)
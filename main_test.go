@@ -0,0 +1,514 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
+	"github.com/samuelattwood/partner-charts-ci/pkg/parse"
+	"github.com/samuelattwood/partner-charts-ci/pkg/validate"
+	"github.com/urfave/cli"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// Creates a git repository in a temporary directory and makes it the working
+// directory, which is used as the repository root
+func chdirTestRepo(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+	repoRoot := t.TempDir()
+	r, err := git.PlainInit(repoRoot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitConfig, err := r.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gitConfig.User.Name = "Test"
+	gitConfig.User.Email = "test@example.com"
+	if err = r.SetConfig(gitConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(repoRoot); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return repoRoot, r
+}
+
+// Returns a chart and its companion CRD chart at the given version
+func testChartWithCRDs(version string) (*chart.Chart, *chart.Chart) {
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       "foo",
+			Version:    version,
+		},
+		Templates: []*chart.File{
+			{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n")},
+		},
+	}
+	crdChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       "foo" + conform.CRDChartSuffix,
+			Version:    version,
+		},
+		Templates: []*chart.File{
+			{Name: "templates/crd.yaml", Data: []byte("apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: foos.example.com\n")},
+		},
+	}
+
+	return helmChart, crdChart
+}
+
+// Stores and indexes two versions of a chart and its companion CRD chart, and
+// commits them
+func storeTestCharts(t *testing.T, repoRoot string, r *git.Repository) {
+	t.Helper()
+	indexManager, err := loadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assetsPath := filepath.Join(repoRoot, repositoryAssetsDir, "acme")
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		helmChart, crdChart := testChartWithCRDs(version)
+		for _, storedChart := range []*chart.Chart{helmChart, crdChart} {
+			chartsPath := filepath.Join(repoRoot, repositoryChartsDir, "acme", storedChart.Name())
+			if err = os.RemoveAll(chartsPath); err != nil {
+				t.Fatal(err)
+			}
+			assetFile, err := saveChart(storedChart, assetsPath, chartsPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = indexManager.Add(assetFile); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err = indexManager.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = wt.AddGlob("."); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wt.Commit("Add charts", &git.CommitOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Returns a CLI context with the given arguments
+func testContext(t *testing.T, args ...string) *cli.Context {
+	t.Helper()
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := flagSet.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return cli.NewContext(cli.NewApp(), flagSet, nil)
+}
+
+// Returns the latest commit message of the repository
+func headMessage(t *testing.T, r *git.Repository) string {
+	t.Helper()
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return commit.Message
+}
+
+func TestRemoveChartVersionWithCRDChart(t *testing.T) {
+	repoRoot, r := chdirTestRepo(t)
+	storeTestCharts(t, repoRoot, r)
+
+	removeChartVersion(testContext(t, "acme/foo", "1.1.0"))
+
+	indexManager, err := loadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chartName := range []string{"foo", "foo" + conform.CRDChartSuffix} {
+		chartVersions := indexManager.IndexFile.Entries[chartName]
+		if len(chartVersions) != 1 || chartVersions[0].Version != "1.0.0" {
+			t.Errorf("expected only %s 1.0.0 to remain indexed, got %v", chartName, chartVersions)
+		}
+		if _, err := os.Stat(filepath.Join(repoRoot, repositoryAssetsDir, "acme", chartName+"-1.1.0.tgz")); !os.IsNotExist(err) {
+			t.Errorf("expected asset of %s 1.1.0 to be removed, got %v", chartName, err)
+		}
+
+		chartsPath := filepath.Join(repoRoot, repositoryChartsDir, "acme", chartName)
+		storedChart, err := loader.Load(chartsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if storedChart.Metadata.Version != "1.0.0" {
+			t.Errorf("expected chart directory of %s to be refreshed to 1.0.0, got %s", chartName, storedChart.Metadata.Version)
+		}
+
+		//The refreshed directory must match the one written when the chart is saved
+		helmChart, crdChart := testChartWithCRDs("1.0.0")
+		savedChart := helmChart
+		if chartName != helmChart.Name() {
+			savedChart = crdChart
+		}
+		savedPath := filepath.Join(t.TempDir(), chartName)
+		if _, err = saveChart(savedChart, t.TempDir(), savedPath); err != nil {
+			t.Fatal(err)
+		}
+		comparison, err := validate.CompareDirectories(savedPath, chartsPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !comparison.Match {
+			t.Errorf("refreshed chart directory of %s differs from saved chart: %+v", chartName, comparison)
+		}
+	}
+
+	if message := headMessage(t, r); !strings.Contains(message, "acme/foo-crd") {
+		t.Errorf("expected commit to list acme/foo-crd, got %s", message)
+	}
+}
+
+func TestDeprecateChartWithCRDChart(t *testing.T) {
+	repoRoot, r := chdirTestRepo(t)
+	storeTestCharts(t, repoRoot, r)
+
+	deprecateChart(testContext(t, "acme/foo"))
+
+	indexManager, err := loadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chartName := range []string{"foo", "foo" + conform.CRDChartSuffix} {
+		for _, chartVersion := range indexManager.IndexFile.Entries[chartName] {
+			if !chartVersion.Deprecated {
+				t.Errorf("expected %s (%s) to be deprecated", chartName, chartVersion.Version)
+			}
+		}
+		storedChart, err := loader.Load(filepath.Join(repoRoot, repositoryChartsDir, "acme", chartName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !storedChart.Metadata.Deprecated {
+			t.Errorf("expected chart directory of %s to be deprecated", chartName)
+		}
+	}
+
+	if message := headMessage(t, r); !strings.Contains(message, "acme/foo-crd") {
+		t.Errorf("expected commit to list acme/foo-crd, got %s", message)
+	}
+}
+
+// Creates a git repository holding a chart with the given name and returns
+// its path
+func initUpstreamGitRepo(t *testing.T, chartName string) string {
+	t.Helper()
+	upstreamPath := t.TempDir()
+	r, err := git.PlainInit(upstreamPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chartYaml := "apiVersion: v2\nname: " + chartName + "\nversion: 1.0.0\n"
+	if err = os.WriteFile(filepath.Join(upstreamPath, "Chart.yaml"), []byte(chartYaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wt.Add("Chart.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	commitOptions := &git.CommitOptions{Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}}
+	if _, err = wt.Commit("Add chart", commitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	return upstreamPath
+}
+
+func TestPackageChartName(t *testing.T) {
+	repoRoot, _ := chdirTestRepo(t)
+	packagePath := filepath.Join(repoRoot, repositoryPackagesDir, "acme", "foo-package")
+	storedCharts := map[string]bool{
+		"acme/foo-package": false,
+		"acme/foo-ah":      true,
+	}
+
+	tests := []struct {
+		name         string
+		upstreamYaml parse.UpstreamYaml
+		storedCharts map[string]bool
+		expected     string
+	}{
+		{
+			name:         "chart metadata name",
+			upstreamYaml: parse.UpstreamYaml{ChartYaml: chart.Metadata{Name: "renamed"}, HelmChart: "foo"},
+			storedCharts: storedCharts,
+			expected:     "renamed",
+		},
+		{
+			name:         "helm chart",
+			upstreamYaml: parse.UpstreamYaml{HelmChart: "foo"},
+			storedCharts: storedCharts,
+			expected:     "foo",
+		},
+		{
+			name:         "stored artifact hub package",
+			upstreamYaml: parse.UpstreamYaml{AHPackageName: "foo-ah"},
+			storedCharts: storedCharts,
+			expected:     "foo-ah",
+		},
+		{
+			name:         "stored package directory",
+			upstreamYaml: parse.UpstreamYaml{GitRepoUrl: "invalid"},
+			storedCharts: map[string]bool{"acme/foo-package": true},
+			expected:     "foo-package",
+		},
+		{
+			name:         "upstream git chart",
+			upstreamYaml: parse.UpstreamYaml{GitRepoUrl: initUpstreamGitRepo(t, "foo-git")},
+			storedCharts: storedCharts,
+			expected:     "foo-git",
+		},
+		{
+			name:         "unreadable upstream",
+			upstreamYaml: parse.UpstreamYaml{GitRepoUrl: filepath.Join(repoRoot, "missing")},
+			storedCharts: storedCharts,
+			expected:     "foo-package",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chartName := packageChartName(packagePath, test.upstreamYaml, test.storedCharts)
+			if chartName != test.expected {
+				t.Errorf("expected '%s', got '%s'", test.expected, chartName)
+			}
+		})
+	}
+}
+
+func TestPublishedVersion(t *testing.T) {
+	packageVersion := 1
+	tests := []struct {
+		name           string
+		packageWrapper *PackageWrapper
+		expected       string
+	}{
+		{
+			name:           "package version",
+			packageWrapper: &PackageWrapper{UpstreamYaml: &parse.UpstreamYaml{PackageVersion: 1}},
+			expected:       "1.2.301",
+		},
+		{
+			name:           "package version format",
+			packageWrapper: &PackageWrapper{UpstreamYaml: &parse.UpstreamYaml{PackageVersion: 2, PackageVersionFormat: conform.PackageVersionBuild}},
+			expected:       "1.2.3+rancher.2",
+		},
+		{
+			name:           "no package version",
+			packageWrapper: &PackageWrapper{UpstreamYaml: &parse.UpstreamYaml{}},
+			expected:       "1.2.3",
+		},
+		{
+			name:           "package.yaml package version",
+			packageWrapper: &PackageWrapper{ManualUpdate: true, PackageVersion: &packageVersion},
+			expected:       "1.2.301",
+		},
+		{
+			name:           "package.yaml fixed version",
+			packageWrapper: &PackageWrapper{ManualUpdate: true, PackageVersion: &packageVersion, FixedVersion: "1.2.4"},
+			expected:       "1.2.4",
+		},
+		{
+			name:           "package.yaml without package version",
+			packageWrapper: &PackageWrapper{ManualUpdate: true},
+			expected:       "1.2.3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, err := publishedVersion(test.packageWrapper, "1.2.3")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, version)
+			}
+		})
+	}
+}
+
+func TestCheckKubeAPIs(t *testing.T) {
+	helmChart, _ := testChartWithCRDs("1.0.0")
+	if err := checkKubeAPIs(helmChart, nil); err != nil {
+		t.Errorf("expected served APIs to pass, got %v", err)
+	}
+
+	helmChart.Templates = append(helmChart.Templates, &chart.File{
+		Name: "templates/cronjob.yaml",
+		Data: []byte("apiVersion: batch/v1beta1\nkind: CronJob\nmetadata:\n  name: foo\n"),
+	})
+	if err := checkKubeAPIs(helmChart, nil); err == nil {
+		t.Error("expected removed API to fail")
+	}
+}
+
+func TestLoadKubeSchemasNotFound(t *testing.T) {
+	chdirTestRepo(t)
+	kubeSchemas, err := loadKubeSchemas(validate.ConfigurationYaml{})
+	if kubeSchemas != nil || err != nil {
+		t.Errorf("expected no schemas without KubeSchemas, got %v, %v", kubeSchemas, err)
+	}
+
+	_, err = loadKubeSchemas(validate.ConfigurationYaml{KubeSchemas: "schemas/kubernetes"})
+	if err == nil || !strings.Contains(err.Error(), "schemas not found") {
+		t.Errorf("expected schemas not found error, got %v", err)
+	}
+}
+
+// Returns a CLI context with the given boolean flags set
+func testFlagContext(t *testing.T, flags ...string) *cli.Context {
+	t.Helper()
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	for _, name := range flags {
+		flagSet.Bool(name, true, "")
+	}
+
+	return cli.NewContext(cli.NewApp(), flagSet, nil)
+}
+
+func TestAnnotate(t *testing.T) {
+	repoRoot, r := chdirTestRepo(t)
+	storeTestCharts(t, repoRoot, r)
+
+	latestStored, err := getLatestStoredVersion("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	packageWrapper := PackageWrapper{Name: "foo", ParsedVendor: "acme", LatestStored: latestStored}
+	indexManager, err := loadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = packageWrapper.annotate(indexManager, annotationHidden, "true", false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chartVersion := range indexManager.IndexFile.Entries["foo"] {
+		if chartVersion.Annotations[annotationHidden] != "true" {
+			t.Errorf("expected index entry of %s to be hidden", chartVersion.Version)
+		}
+		storedChart, err := loader.LoadFile(filepath.Join(repoRoot, chartVersion.URLs[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if storedChart.Metadata.Annotations[annotationHidden] != "true" {
+			t.Errorf("expected asset of %s to be hidden", chartVersion.Version)
+		}
+	}
+
+	storedChart, err := loader.Load(filepath.Join(repoRoot, repositoryChartsDir, "acme", "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedChart.Metadata.Version != "1.1.0" || storedChart.Metadata.Annotations[annotationHidden] != "true" {
+		t.Errorf("expected chart directory to hold hidden 1.1.0, got %s %v", storedChart.Metadata.Version, storedChart.Metadata.Annotations)
+	}
+}
+
+func TestCheckRepoFix(t *testing.T) {
+	repoRoot, r := chdirTestRepo(t)
+	storeTestCharts(t, repoRoot, r)
+	if err := os.Remove(filepath.Join(repoRoot, indexFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	checkRepo(testFlagContext(t, "fix"))
+
+	indexYaml, err := readIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chartName := range []string{"foo", "foo" + conform.CRDChartSuffix} {
+		if len(indexYaml.Entries[chartName]) != 2 {
+			t.Errorf("expected both versions of %s to be indexed, got %v", chartName, indexYaml.Entries[chartName])
+		}
+	}
+}
+
+func TestValidateRepoPolicy(t *testing.T) {
+	repoRoot, _ := chdirTestRepo(t)
+	configYaml := "AnnotationPolicy:\n  Forbidden:\n  - catalog.cattle.io/*\nValidate:\n- Url: https://github.com/rancher/partner-charts\n  Branch: main-source\n"
+	if err := os.WriteFile(filepath.Join(repoRoot, configOptionsFile), []byte(configYaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	indexYaml := repo.NewIndexFile()
+	for name, annotations := range map[string]map[string]string{
+		"foo": {annotationCertified: "partner", annotationDisplayName: "Foo", annotationReleaseName: "foo"},
+		"bar": {annotationCertified: "partner", "catalog.cattle.io/ui-component": "bar"},
+	} {
+		metadata := &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: "1.0.0", Annotations: annotations}
+		if err := indexYaml.MustAdd(metadata, name+"-1.0.0.tgz", "assets/acme", "sha256:0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := indexYaml.WriteFile(filepath.Join(repoRoot, indexFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := readConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	helmIndexYaml, err := readIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	policyViolations := config.AnnotationPolicy.CheckIndex(helmIndexYaml)
+	if len(policyViolations) != 1 || policyViolations[0].Chart != "bar" {
+		t.Fatalf("expected only bar to violate the policy, got %+v", policyViolations)
+	}
+	expected := []string{"forbidden annotation catalog.cattle.io/ui-component is set"}
+	if !reflect.DeepEqual(policyViolations[0].Violations, expected) {
+		t.Errorf("expected violations %v, got %v", expected, policyViolations[0].Violations)
+	}
+
+	if !reportPolicyViolations(policyViolations, []string{"acme/bar-1.0.0.tgz"}) {
+		t.Error("added asset violating the policy does not fail validation")
+	}
+	if reportPolicyViolations(policyViolations, []string{"acme/foo-1.0.0.tgz"}) {
+		t.Error("released asset violating the policy fails validation")
+	}
+}
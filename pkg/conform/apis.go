@@ -0,0 +1,117 @@
+package conform
+
+import (
+	"strconv"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// DeprecatedAPI describes a built-in Kubernetes API that is deprecated or
+// removed in a 1.x minor release
+type DeprecatedAPI struct {
+	APIVersion string
+	Kind       string
+	//DeprecatedIn is the minor version deprecating the API
+	DeprecatedIn int
+	//RemovedIn is the minor version no longer serving the API
+	RemovedIn int
+	//Replacement is the API version to migrate to
+	Replacement string
+}
+
+// DeprecatedAPIs lists every kind served by each deprecated API version
+var DeprecatedAPIs = []DeprecatedAPI{
+	{"extensions/v1beta1", "DaemonSet", 8, 16, "apps/v1"},
+	{"extensions/v1beta1", "Deployment", 8, 16, "apps/v1"},
+	{"extensions/v1beta1", "ReplicaSet", 8, 16, "apps/v1"},
+	{"extensions/v1beta1", "NetworkPolicy", 9, 16, "networking.k8s.io/v1"},
+	{"extensions/v1beta1", "PodSecurityPolicy", 10, 16, "policy/v1beta1"},
+	{"extensions/v1beta1", "Ingress", 14, 22, "networking.k8s.io/v1"},
+	{"apps/v1beta1", "Deployment", 9, 16, "apps/v1"},
+	{"apps/v1beta1", "StatefulSet", 9, 16, "apps/v1"},
+	{"apps/v1beta1", "ControllerRevision", 9, 16, "apps/v1"},
+	{"apps/v1beta2", "DaemonSet", 9, 16, "apps/v1"},
+	{"apps/v1beta2", "Deployment", 9, 16, "apps/v1"},
+	{"apps/v1beta2", "ReplicaSet", 9, 16, "apps/v1"},
+	{"apps/v1beta2", "StatefulSet", 9, 16, "apps/v1"},
+	{"apps/v1beta2", "ControllerRevision", 9, 16, "apps/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "MutatingWebhookConfiguration", 16, 22, "admissionregistration.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", "ValidatingWebhookConfiguration", 16, 22, "admissionregistration.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", "CustomResourceDefinition", 16, 22, "apiextensions.k8s.io/v1"},
+	{"apiregistration.k8s.io/v1beta1", "APIService", 19, 22, "apiregistration.k8s.io/v1"},
+	{"authentication.k8s.io/v1beta1", "TokenReview", 19, 22, "authentication.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "LocalSubjectAccessReview", 19, 22, "authorization.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "SelfSubjectAccessReview", 19, 22, "authorization.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "SelfSubjectRulesReview", 19, 22, "authorization.k8s.io/v1"},
+	{"authorization.k8s.io/v1beta1", "SubjectAccessReview", 19, 22, "authorization.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", "CertificateSigningRequest", 19, 22, "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", "Lease", 19, 22, "coordination.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "Ingress", 19, 22, "networking.k8s.io/v1"},
+	{"networking.k8s.io/v1beta1", "IngressClass", 19, 22, "networking.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRole", 17, 22, "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "ClusterRoleBinding", 17, 22, "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "Role", 17, 22, "rbac.authorization.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", "RoleBinding", 17, 22, "rbac.authorization.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", "PriorityClass", 14, 22, "scheduling.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIDriver", 19, 22, "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSINode", 17, 22, "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "StorageClass", 19, 22, "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "VolumeAttachment", 19, 22, "storage.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", "CSIStorageCapacity", 24, 27, "storage.k8s.io/v1"},
+	{"batch/v1beta1", "CronJob", 21, 25, "batch/v1"},
+	{"discovery.k8s.io/v1beta1", "EndpointSlice", 21, 25, "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", "Event", 19, 25, "events.k8s.io/v1"},
+	{"autoscaling/v2beta1", "HorizontalPodAutoscaler", 22, 25, "autoscaling/v2"},
+	{"autoscaling/v2beta2", "HorizontalPodAutoscaler", 23, 26, "autoscaling/v2"},
+	{"node.k8s.io/v1beta1", "RuntimeClass", 20, 25, "node.k8s.io/v1"},
+	{"policy/v1beta1", "PodDisruptionBudget", 21, 25, "policy/v1"},
+	{"policy/v1beta1", "PodSecurityPolicy", 21, 25, "Pod Security Admission"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "FlowSchema", 23, 26, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", "PriorityLevelConfiguration", 23, 26, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "FlowSchema", 26, 29, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", "PriorityLevelConfiguration", 26, 29, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "FlowSchema", 29, 32, "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", "PriorityLevelConfiguration", 29, 32, "flowcontrol.apiserver.k8s.io/v1"},
+}
+
+// Returns the deprecated API entry for the given API version and kind, if any
+func FindDeprecatedAPI(apiVersion, kind string) (DeprecatedAPI, bool) {
+	for _, api := range DeprecatedAPIs {
+		if api.APIVersion == apiVersion && api.Kind == kind {
+			return api, true
+		}
+	}
+
+	return DeprecatedAPI{}, false
+}
+
+// Parses the minor release of a 1.x Kubernetes version
+func KubeMinorVersion(kubeVersion string) (int, error) {
+	parsedKubeVersion, err := chartutil.ParseKubeVersion(kubeVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSuffix(parsedKubeVersion.Minor, "+"))
+}
+
+// Removes API versions no longer served by the given minor release
+func removedAPIVersions(versionSet chartutil.VersionSet, minor int) chartutil.VersionSet {
+	removedIn := make(map[string]int)
+	for _, api := range DeprecatedAPIs {
+		if api.RemovedIn > removedIn[api.APIVersion] {
+			removedIn[api.APIVersion] = api.RemovedIn
+		}
+	}
+
+	served := make(chartutil.VersionSet, 0, len(versionSet))
+	for _, apiVersion := range versionSet {
+		if removed, ok := removedIn[apiVersion]; ok && removed <= minor {
+			continue
+		}
+		served = append(served, apiVersion)
+	}
+
+	return served
+}
@@ -0,0 +1,83 @@
+package conform
+
+import (
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+const chartLockFile = "Chart.lock"
+
+func removeChartFiles(files []*chart.File, names ...string) []*chart.File {
+	kept := make([]*chart.File, 0, len(files))
+	for _, f := range files {
+		removed := false
+		for _, name := range names {
+			if f.Name == name {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			kept = append(kept, f)
+		}
+	}
+
+	return kept
+}
+
+func getRawFile(helmChart *chart.Chart, name string) *chart.File {
+	for _, f := range helmChart.Raw {
+		if f.Name == name {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// Returns an error if a v1 chart declares dependencies or their lock both in
+// the files used by v1 charts and in those used by v2 charts, as either would
+// be discarded when upgraded
+func checkDependencySources(helmChart *chart.Chart) error {
+	if chartYaml := getRawFile(helmChart, chartutil.ChartfileName); chartYaml != nil && getRawFile(helmChart, requirementsFile) != nil {
+		metadata := chart.Metadata{}
+		if err := yaml.Unmarshal(chartYaml.Data, &metadata); err != nil {
+			return err
+		}
+		if len(metadata.Dependencies) > 0 {
+			return fmt.Errorf("dependencies are declared in both %s and %s", chartutil.ChartfileName, requirementsFile)
+		}
+	}
+	if getRawFile(helmChart, chartLockFile) != nil && getRawFile(helmChart, requirementsLockFile) != nil {
+		return fmt.Errorf("dependencies are locked in both %s and %s", chartLockFile, requirementsLockFile)
+	}
+
+	return nil
+}
+
+// Converts an apiVersion v1 chart to v2. Dependencies loaded from
+// requirements.yaml are kept in Chart.yaml and the lock loaded from
+// requirements.lock is written as Chart.lock. Returns true if upgraded
+func UpgradeChartAPIVersion(helmChart *chart.Chart) (bool, error) {
+	if helmChart.Metadata.APIVersion != chart.APIVersionV1 {
+		return false, nil
+	}
+	if err := checkDependencySources(helmChart); err != nil {
+		return false, fmt.Errorf("unable to upgrade %s (%s) to chart apiVersion %s: %w",
+			helmChart.Name(), helmChart.Metadata.Version, chart.APIVersionV2, err)
+	}
+
+	helmChart.Metadata.APIVersion = chart.APIVersionV2
+	if helmChart.Metadata.Type == "" {
+		helmChart.Metadata.Type = "application"
+	}
+
+	helmChart.Files = removeChartFiles(helmChart.Files, requirementsFile, requirementsLockFile)
+	helmChart.Raw = removeChartFiles(helmChart.Raw, requirementsFile, requirementsLockFile)
+
+	return true, nil
+}
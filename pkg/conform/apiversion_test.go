@@ -0,0 +1,129 @@
+package conform
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+const (
+	testRequirements = "dependencies:\n- name: bar\n  version: 0.1.0\n  repository: https://charts.example.com\n"
+	testLock         = "dependencies:\n- name: bar\n  version: 0.1.0\n  repository: https://charts.example.com\ndigest: sha256:0\ngenerated: \"2020-01-01T00:00:00Z\"\n"
+)
+
+// Writes and loads a chart from the given files
+func loadTestChart(t *testing.T, files map[string]string) *chart.Chart {
+	t.Helper()
+	chartPath := t.TempDir()
+	writeTestFiles(t, chartPath, files)
+	helmChart, err := loader.Load(chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return helmChart
+}
+
+func TestUpgradeChartAPIVersion(t *testing.T) {
+	helmChart := loadTestChart(t, map[string]string{
+		"Chart.yaml":         "apiVersion: v1\nname: foo\nversion: 1.2.3\n",
+		requirementsFile:     testRequirements,
+		requirementsLockFile: testLock,
+		"templates/cm.yaml":  "kind: ConfigMap\n",
+	})
+	dependencies := helmChart.Metadata.Dependencies
+	lock := helmChart.Lock
+
+	upgraded, err := UpgradeChartAPIVersion(helmChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !upgraded {
+		t.Fatal("expected chart to be upgraded")
+	}
+
+	archive, err := ChartArchive(helmChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upgradedChart, err := loader.LoadArchive(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upgradedChart.Metadata.APIVersion != chart.APIVersionV2 || upgradedChart.Metadata.Type != "application" {
+		t.Errorf("expected v2 application chart, got %s %s", upgradedChart.Metadata.APIVersion, upgradedChart.Metadata.Type)
+	}
+	if len(dependencies) != 1 || !reflect.DeepEqual(upgradedChart.Metadata.Dependencies, dependencies) {
+		t.Errorf("expected Chart.yaml dependencies %+v, got %+v", dependencies, upgradedChart.Metadata.Dependencies)
+	}
+	if lock == nil || !reflect.DeepEqual(upgradedChart.Lock, lock) {
+		t.Errorf("expected Chart.lock %+v, got %+v", lock, upgradedChart.Lock)
+	}
+	if getRawFile(upgradedChart, chartLockFile) == nil {
+		t.Error("expected Chart.lock in upgraded chart")
+	}
+	for _, name := range []string{requirementsFile, requirementsLockFile} {
+		if getRawFile(upgradedChart, name) != nil || getChartFile(upgradedChart, name) != nil {
+			t.Errorf("expected %s to be removed", name)
+		}
+	}
+}
+
+func TestUpgradeChartAPIVersionNoop(t *testing.T) {
+	chartYaml := "apiVersion: v2\nname: foo\nversion: 1.2.3\ndependencies:\n- name: bar\n  version: 0.1.0\n  repository: https://charts.example.com\n"
+	helmChart := loadTestChart(t, map[string]string{
+		"Chart.yaml":  chartYaml,
+		chartLockFile: testLock,
+	})
+	before, err := ChartArchive(helmChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upgraded, err := UpgradeChartAPIVersion(helmChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upgraded {
+		t.Error("expected v2 chart not to be upgraded")
+	}
+	after, err := ChartArchive(helmChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("expected v2 chart to be unchanged")
+	}
+}
+
+func TestUpgradeChartAPIVersionConflicts(t *testing.T) {
+	tests := map[string]map[string]string{
+		"dependencies": {
+			"Chart.yaml":     "apiVersion: v1\nname: foo\nversion: 1.2.3\n" + testRequirements,
+			requirementsFile: testRequirements,
+		},
+		"locks": {
+			"Chart.yaml":         "apiVersion: v1\nname: foo\nversion: 1.2.3\n",
+			requirementsFile:     testRequirements,
+			requirementsLockFile: testLock,
+			chartLockFile:        testLock,
+		},
+	}
+
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			helmChart := loadTestChart(t, files)
+			upgraded, err := UpgradeChartAPIVersion(helmChart)
+			if err == nil {
+				t.Error("expected error")
+			}
+			if upgraded || helmChart.Metadata.APIVersion != chart.APIVersionV1 {
+				t.Error("expected chart not to be upgraded")
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package conform
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	//archiveModTime is set on every archive entry so that identical charts produce identical archives
+	archiveModTime = time.Unix(0, 0).UTC()
+	//archiveHeaderExtra matches the gzip header written by Helm
+	archiveHeaderExtra = []byte("+aHR0cHM6Ly95b3V0dS5iZS96OVV6MWljandyTQo=")
+)
+
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+func sortedChartFiles(files []*chart.File) []*chart.File {
+	sorted := make([]*chart.File, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	return sorted
+}
+
+// Collects chart files in the layout written by Helm, with templates, files,
+// and dependencies in sorted order
+func chartArchiveFiles(helmChart *chart.Chart, prefix string) ([]archiveFile, error) {
+	base := path.Join(prefix, helmChart.Name())
+	files := make([]archiveFile, 0)
+
+	//Dependencies of a v1 chart are stored in requirements.yaml
+	savedDependencies := helmChart.Metadata.Dependencies
+	if helmChart.Metadata.APIVersion == chart.APIVersionV1 {
+		helmChart.Metadata.Dependencies = nil
+	}
+	chartYaml, err := yaml.Marshal(helmChart.Metadata)
+	helmChart.Metadata.Dependencies = savedDependencies
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, archiveFile{path.Join(base, chartutil.ChartfileName), chartYaml})
+
+	if helmChart.Metadata.APIVersion == chart.APIVersionV2 && helmChart.Lock != nil {
+		lockYaml, err := yaml.Marshal(helmChart.Lock)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, archiveFile{path.Join(base, "Chart.lock"), lockYaml})
+	}
+
+	for _, f := range helmChart.Raw {
+		if f.Name == chartutil.ValuesfileName {
+			files = append(files, archiveFile{path.Join(base, chartutil.ValuesfileName), f.Data})
+		}
+	}
+
+	if helmChart.Schema != nil {
+		if !json.Valid(helmChart.Schema) {
+			return nil, errors.New("Invalid JSON in " + chartutil.SchemafileName)
+		}
+		files = append(files, archiveFile{path.Join(base, chartutil.SchemafileName), helmChart.Schema})
+	}
+
+	for _, f := range sortedChartFiles(helmChart.Templates) {
+		files = append(files, archiveFile{path.Join(base, filepath.ToSlash(f.Name)), f.Data})
+	}
+
+	for _, f := range sortedChartFiles(helmChart.Files) {
+		files = append(files, archiveFile{path.Join(base, filepath.ToSlash(f.Name)), f.Data})
+	}
+
+	dependencies := make([]*chart.Chart, len(helmChart.Dependencies()))
+	copy(dependencies, helmChart.Dependencies())
+	sort.SliceStable(dependencies, func(i, j int) bool {
+		return dependencies[i].Name() < dependencies[j].Name()
+	})
+	for _, dependency := range dependencies {
+		dependencyFiles, err := chartArchiveFiles(dependency, path.Join(base, chartutil.ChartsDir))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dependencyFiles...)
+	}
+
+	return files, nil
+}
+
+// Generates a chart archive whose bytes depend only on the chart content
+func ChartArchive(helmChart *chart.Chart) ([]byte, error) {
+	if err := helmChart.Validate(); err != nil {
+		return nil, fmt.Errorf("chart validation: %w", err)
+	}
+
+	files, err := chartArchiveFiles(helmChart, "")
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	zipper := gzip.NewWriter(buf)
+	zipper.Header.Extra = archiveHeaderExtra
+	zipper.Header.Comment = "Helm"
+	zipper.Header.ModTime = time.Time{}
+	zipper.Header.OS = 255
+
+	twriter := tar.NewWriter(zipper)
+	for _, f := range files {
+		header := &tar.Header{
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(f.data)),
+			ModTime:  archiveModTime,
+			Typeflag: tar.TypeReg,
+		}
+		if err := twriter.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := twriter.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := twriter.Close(); err != nil {
+		return nil, err
+	}
+	if err := zipper.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Writes the chart archive to outDir as <name>-<version>.tgz. An existing
+// archive with identical content is left untouched
+func saveChartArchive(helmChart *chart.Chart, outDir string) (string, error) {
+	archive, err := ChartArchive(helmChart)
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(outDir, fmt.Sprintf("%s-%s.tgz", helmChart.Name(), helmChart.Metadata.Version))
+	if existing, err := os.ReadFile(filename); err == nil && bytes.Equal(existing, archive) {
+		logrus.Debugf("%s unchanged\n", filename)
+		return filename, nil
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return "", err
+	}
+
+	tempFile, err := os.CreateTemp(outDir, ".chartArchive")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(archive); err != nil {
+		tempFile.Close()
+		return "", err
+	}
+	if err := tempFile.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tempFile.Name(), 0644); err != nil {
+		return "", err
+	}
+
+	return filename, os.Rename(tempFile.Name(), filename)
+}
@@ -0,0 +1,266 @@
+package conform
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// Writes the given files, keyed by path relative to root
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filePath := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Returns a chart with a dependency, a lock, a schema, and several templates
+// and files, listed in the given order
+func testArchiveChart(reversed bool) *chart.Chart {
+	dependency := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "bar", Version: "0.1.0"},
+		Templates: []*chart.File{
+			{Name: "templates/service.yaml", Data: []byte("kind: Service\n")},
+		},
+	}
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       "foo",
+			Version:    "1.2.3",
+			Dependencies: []*chart.Dependency{
+				{Name: "bar", Version: "0.1.0", Repository: "file://./charts/bar"},
+			},
+		},
+		Lock: &chart.Lock{
+			Generated:    time.Unix(0, 0).UTC(),
+			Digest:       "sha256:0",
+			Dependencies: []*chart.Dependency{{Name: "bar", Version: "0.1.0", Repository: "file://./charts/bar"}},
+		},
+		Schema: []byte(`{"type":"object"}`),
+		Raw: []*chart.File{
+			{Name: chartutil.ValuesfileName, Data: []byte("replicas: 1\n")},
+		},
+		Values: map[string]interface{}{"replicas": float64(1)},
+		Templates: []*chart.File{
+			{Name: "templates/configmap.yaml", Data: []byte("kind: ConfigMap\n")},
+			{Name: "templates/deployment.yaml", Data: []byte("kind: Deployment\n")},
+			{Name: "templates/_helpers.tpl", Data: []byte("{{- define \"foo\" }}{{ end }}\n")},
+		},
+		Files: []*chart.File{
+			{Name: "README.md", Data: []byte("foo\n")},
+			{Name: "app-readme.md", Data: []byte("Foo\n")},
+		},
+	}
+	helmChart.SetDependencies(dependency)
+
+	if reversed {
+		for _, files := range [][]*chart.File{helmChart.Templates, helmChart.Files} {
+			for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+				files[i], files[j] = files[j], files[i]
+			}
+		}
+	}
+
+	return helmChart
+}
+
+func TestChartArchiveReproducible(t *testing.T) {
+	first, err := ChartArchive(testArchiveChart(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ChartArchive(testArchiveChart(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("archives of the same chart differ")
+	}
+
+	reversed, err := ChartArchive(testArchiveChart(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, reversed) {
+		t.Error("archives of the same chart with files in another order differ")
+	}
+
+	firstFile, err := saveChartArchive(testArchiveChart(false), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondFile, err := saveChartArchive(testArchiveChart(true), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstData, err := os.ReadFile(firstFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondData, err := os.ReadFile(secondFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(firstData, secondData) || !bytes.Equal(firstData, first) {
+		t.Error("saved archives of the same chart differ")
+	}
+}
+
+func TestChartArchiveDirectoryWriteOrder(t *testing.T) {
+	files := [][2]string{
+		{"Chart.yaml", "apiVersion: v2\nname: foo\nversion: 1.2.3\n"},
+		{"values.yaml", "replicas: 1\n"},
+		{"templates/configmap.yaml", "kind: ConfigMap\n"},
+		{"templates/deployment.yaml", "kind: Deployment\n"},
+		{"README.md", "foo\n"},
+		{"files/config.txt", "config\n"},
+	}
+
+	archives := make([][]byte, 0, 2)
+	for _, reversed := range []bool{false, true} {
+		chartPath := t.TempDir()
+		for i := range files {
+			f := files[i]
+			if reversed {
+				f = files[len(files)-1-i]
+			}
+			writeTestFiles(t, chartPath, map[string]string{f[0]: f[1]})
+			modTime := time.Now().Add(time.Duration(i) * time.Hour)
+			if err := os.Chtimes(filepath.Join(chartPath, f[0]), modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		helmChart, err := loader.Load(chartPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archive, err := ChartArchive(helmChart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archives = append(archives, archive)
+	}
+
+	if !bytes.Equal(archives[0], archives[1]) {
+		t.Error("archives of charts written in another order differ")
+	}
+}
+
+func TestSaveChartArchiveUnchanged(t *testing.T) {
+	outDir := t.TempDir()
+	archiveFile, err := saveChartArchive(testArchiveChart(false), outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	if err = os.Chtimes(archiveFile, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = saveChartArchive(testArchiveChart(true), outDir); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(archiveFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("expected unchanged archive to keep modification time %s, got %s", modTime, info.ModTime())
+	}
+
+	changedChart := testArchiveChart(false)
+	changedChart.Files[0].Data = []byte("changed\n")
+	if _, err = saveChartArchive(changedChart, outDir); err != nil {
+		t.Fatal(err)
+	}
+	info, err = os.Stat(archiveFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ModTime().Equal(modTime) {
+		t.Error("expected changed archive to be written")
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the archive in %s, got %d entries", outDir, len(entries))
+	}
+}
+
+// Returns the chart content compared between archives, keyed by file name
+func loadedChartContent(t *testing.T, archiveFile string) (*chart.Chart, map[string]string) {
+	t.Helper()
+	archive, err := os.Open(archiveFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	helmChart, err := loader.LoadArchive(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := make(map[string]string)
+	var collect func(c *chart.Chart, prefix string)
+	collect = func(c *chart.Chart, prefix string) {
+		for _, f := range append(append(append([]*chart.File{}, c.Raw...), c.Templates...), c.Files...) {
+			content[prefix+f.Name] = string(f.Data)
+		}
+		for _, dependency := range c.Dependencies() {
+			collect(dependency, prefix+"charts/"+dependency.Name()+"/")
+		}
+	}
+	collect(helmChart, "")
+
+	return helmChart, content
+}
+
+func TestChartArchiveMatchesHelm(t *testing.T) {
+	archiveFile, err := saveChartArchive(testArchiveChart(false), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	helmArchiveFile, err := chartutil.Save(testArchiveChart(false), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archivedChart, content := loadedChartContent(t, archiveFile)
+	helmChart, helmContent := loadedChartContent(t, helmArchiveFile)
+
+	if !reflect.DeepEqual(content, helmContent) {
+		t.Errorf("expected archive content %v, got %v", helmContent, content)
+	}
+	if !reflect.DeepEqual(archivedChart.Metadata, helmChart.Metadata) {
+		t.Errorf("expected metadata %+v, got %+v", helmChart.Metadata, archivedChart.Metadata)
+	}
+	if !reflect.DeepEqual(archivedChart.Values, helmChart.Values) {
+		t.Errorf("expected values %v, got %v", helmChart.Values, archivedChart.Values)
+	}
+	if !reflect.DeepEqual(archivedChart.Lock, helmChart.Lock) {
+		t.Errorf("expected lock %+v, got %+v", helmChart.Lock, archivedChart.Lock)
+	}
+	if !bytes.Equal(archivedChart.Schema, helmChart.Schema) {
+		t.Errorf("expected schema %s, got %s", helmChart.Schema, archivedChart.Schema)
+	}
+}
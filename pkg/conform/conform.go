@@ -1,21 +1,11 @@
 package conform
 
 import (
-	"fmt"
-	"math"
-	"strings"
-
-	"github.com/Masterminds/semver/v3"
 	"github.com/sirupsen/logrus"
 
 	"helm.sh/helm/v3/pkg/chart"
 )
 
-var (
-	PatchNumMultiplier = uint64(math.Pow10(2))
-	MaxPatchNum        = PatchNumMultiplier - 1
-)
-
 func OverlayChartMetadata(helmChart *chart.Chart, overlay chart.Metadata) {
 	if overlay.Name != "" {
 		helmChart.Metadata.Name = overlay.Name
@@ -140,57 +130,3 @@ func RemoveChartAnnotations(helmChart *chart.Chart, annotations map[string]strin
 
 	return modified
 }
-
-func StripPackageVersion(chartVersion string) string {
-	version, err := semver.NewVersion(chartVersion)
-	if err != nil {
-		logrus.Error(err)
-	}
-
-	if version.Patch() >= PatchNumMultiplier {
-		packageVersion := version.Patch() % 2
-		patchVersion := (version.Patch() - packageVersion) / PatchNumMultiplier
-		split := strings.Split(version.String(), ".")
-		split[2] = fmt.Sprintf("%d", patchVersion)
-		version, err = semver.NewVersion(strings.Join(split, "."))
-		if err != nil {
-			logrus.Error(err)
-		}
-	}
-
-	return version.String()
-}
-
-func GeneratePackageVersion(upstreamChartVersion string, packageVersion *int, version string) (string, error) {
-	if version != "" {
-		return version, nil
-	}
-	if packageVersion != nil {
-		chartVersion, err := semver.NewVersion(upstreamChartVersion)
-		if err != nil {
-			return "", err
-		}
-
-		if uint64(*packageVersion) >= MaxPatchNum {
-			return "", fmt.Errorf("package version %d is greater than maximum of %d", *packageVersion, MaxPatchNum)
-		}
-
-		patchVersion := PatchNumMultiplier*chartVersion.Patch() + uint64(*packageVersion)
-
-		split := strings.Split(chartVersion.String(), ".")
-		split[2] = fmt.Sprintf("%d", patchVersion)
-		chartVersion, err = semver.NewVersion(strings.Join(split, "."))
-		if err != nil {
-			return "", err
-		}
-
-		return chartVersion.String(), nil
-	}
-
-	chartVersion, err := semver.NewVersion(upstreamChartVersion)
-	if err != nil {
-		return "", err
-	}
-
-	return chartVersion.String(), nil
-}
@@ -0,0 +1,135 @@
+package conform
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	//CRDChartSuffix is appended to the chart name to form the companion CRD chart name
+	CRDChartSuffix = "-crd"
+	crdsDir        = "crds/"
+	//crdManifestDir holds the contents of the crds directory in the CRD chart
+	crdManifestDir = "crd-manifest/"
+	//crdManifestTemplate writes the files in crdManifestDir without rendering
+	//them, so that CRDs containing template delimiters are installed as is
+	crdManifestTemplate = `{{- range $path, $_ := .Files.Glob "` + crdManifestDir + `**" }}
+---
+{{ $.Files.Get $path }}
+{{- end }}
+`
+	templatesDir = "templates/"
+	valuesFile   = "values.yaml"
+)
+
+var (
+	documentSeparatorRegex = regexp.MustCompile(`(?m)^---`)
+	documentKindRegex      = regexp.MustCompile(`(?m)^kind:\s*["']?([A-Za-z]+)`)
+)
+
+// Returns true if every resource defined in the template is a CustomResourceDefinition
+func isCRDTemplate(template *chart.File) bool {
+	if strings.HasPrefix(path.Base(template.Name), "_") {
+		return false
+	}
+	if ext := path.Ext(template.Name); ext != ".yaml" && ext != ".yml" {
+		return false
+	}
+
+	crdFound := false
+	for _, document := range documentSeparatorRegex.Split(string(template.Data), -1) {
+		for _, match := range documentKindRegex.FindAllStringSubmatch(document, -1) {
+			if match[1] != "CustomResourceDefinition" {
+				return false
+			}
+			crdFound = true
+		}
+	}
+
+	return crdFound
+}
+
+// Moves the contents of the crds directory and any CRD templates into a
+// companion chart of the same version. Files in the crds directory are kept
+// as chart files and installed by a template that does not render them.
+// Returns nil if the chart has no CRDs
+func SplitCRDChart(helmChart *chart.Chart) (*chart.Chart, error) {
+	crdChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:  chart.APIVersionV2,
+			Name:        helmChart.Name() + CRDChartSuffix,
+			Version:     helmChart.Metadata.Version,
+			AppVersion:  helmChart.Metadata.AppVersion,
+			Description: fmt.Sprintf("Installs the CRDs for %s.", helmChart.Name()),
+			Type:        "application",
+			Home:        helmChart.Metadata.Home,
+			Icon:        helmChart.Metadata.Icon,
+			KubeVersion: helmChart.Metadata.KubeVersion,
+		},
+	}
+
+	files := make([]*chart.File, 0, len(helmChart.Files))
+	for _, f := range helmChart.Files {
+		if strings.HasPrefix(f.Name, crdsDir) {
+			logrus.Debugf("Moving %s to %s\n", f.Name, crdChart.Name())
+			crdChart.Files = append(crdChart.Files, &chart.File{
+				Name: crdManifestDir + strings.TrimPrefix(f.Name, crdsDir),
+				Data: f.Data,
+			})
+		} else {
+			files = append(files, f)
+		}
+	}
+	if len(crdChart.Files) > 0 {
+		crdChart.Templates = append(crdChart.Templates, &chart.File{
+			Name: templatesDir + "crd-manifest.yaml",
+			Data: []byte(crdManifestTemplate),
+		})
+	}
+
+	templatesMoved := false
+	templates := make([]*chart.File, 0, len(helmChart.Templates))
+	for _, t := range helmChart.Templates {
+		if isCRDTemplate(t) {
+			logrus.Debugf("Moving %s to %s\n", t.Name, crdChart.Name())
+			crdChart.Templates = append(crdChart.Templates, t)
+			templatesMoved = true
+		} else {
+			templates = append(templates, t)
+		}
+	}
+
+	if len(crdChart.Templates) == 0 {
+		return nil, nil
+	}
+
+	//Moved templates may rely on chart values and helpers
+	if templatesMoved {
+		for _, t := range templates {
+			if strings.HasPrefix(path.Base(t.Name), "_") {
+				crdChart.Templates = append(crdChart.Templates, t)
+			}
+		}
+		for _, f := range helmChart.Raw {
+			if f.Name == valuesFile {
+				crdChart.Raw = append(crdChart.Raw, f)
+			}
+		}
+		crdChart.Values = helmChart.Values
+	}
+
+	helmChart.Files = files
+	helmChart.Templates = templates
+
+	if err := crdChart.Validate(); err != nil {
+		return nil, err
+	}
+
+	return crdChart, nil
+}
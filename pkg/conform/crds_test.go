@@ -0,0 +1,172 @@
+package conform
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+)
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  names:
+    kind: Foo
+    plural: foos
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        description: 'Set to {{ .Values.name }} to template the name'
+        type: object
+`
+
+// Returns the sorted names of the given chart files
+func chartFileNames(files []*chart.File) []string {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func testCRDChart() *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       "foo",
+			Version:    "1.2.3",
+			AppVersion: "0.1.0",
+		},
+		Files: []*chart.File{
+			{Name: "crds/foos.yaml", Data: []byte(testCRD)},
+			{Name: "README.md", Data: []byte("foo\n")},
+		},
+		Templates: []*chart.File{
+			{Name: "templates/_helpers.tpl", Data: []byte(`{{- define "foo.group" -}}{{ .Values.group }}{{- end -}}`)},
+			{Name: "templates/bars.yaml", Data: []byte("apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: bars.{{ include \"foo.group\" . }}\n")},
+			{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n")},
+		},
+		Raw: []*chart.File{
+			{Name: valuesFile, Data: []byte("group: example.com\n")},
+		},
+		Values: map[string]interface{}{"group": "example.com"},
+	}
+}
+
+func TestSplitCRDChart(t *testing.T) {
+	helmChart := testCRDChart()
+	crdChart, err := SplitCRDChart(helmChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crdChart == nil {
+		t.Fatal("expected CRD chart")
+	}
+
+	if crdChart.Name() != "foo"+CRDChartSuffix || crdChart.Metadata.Version != "1.2.3" || crdChart.Metadata.AppVersion != "0.1.0" {
+		t.Errorf("unexpected CRD chart metadata %+v", crdChart.Metadata)
+	}
+	if names := chartFileNames(helmChart.Files); !reflect.DeepEqual(names, []string{"README.md"}) {
+		t.Errorf("expected crds to be removed from chart files, got %v", names)
+	}
+	if names := chartFileNames(helmChart.Templates); !reflect.DeepEqual(names, []string{"templates/_helpers.tpl", "templates/configmap.yaml"}) {
+		t.Errorf("expected CRD templates to be removed from chart, got %v", names)
+	}
+	if names := chartFileNames(crdChart.Files); !reflect.DeepEqual(names, []string{"crd-manifest/foos.yaml"}) {
+		t.Errorf("expected crds to be CRD chart files, got %v", names)
+	}
+	expectedTemplates := []string{"templates/_helpers.tpl", "templates/bars.yaml", "templates/crd-manifest.yaml"}
+	if names := chartFileNames(crdChart.Templates); !reflect.DeepEqual(names, expectedTemplates) {
+		t.Errorf("expected CRD chart templates %v, got %v", expectedTemplates, names)
+	}
+	if names := chartFileNames(crdChart.Raw); !reflect.DeepEqual(names, []string{valuesFile}) {
+		t.Errorf("expected values of moved templates in CRD chart, got %v", names)
+	}
+
+	values, err := chartutil.ToRenderValues(crdChart, nil, chartutil.ReleaseOptions{Name: "foo-crd"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := engine.Render(crdChart, values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest := rendered["foo-crd/templates/crd-manifest.yaml"]; manifest != "\n---\n"+testCRD+"\n" {
+		t.Errorf("expected CRD to be installed unrendered, got %q", manifest)
+	}
+	if manifest := rendered["foo-crd/templates/bars.yaml"]; !strings.Contains(manifest, "name: bars.example.com") {
+		t.Errorf("expected CRD template to be rendered with chart values and helpers, got %q", manifest)
+	}
+}
+
+func TestSplitCRDChartCRDsDirectoryOnly(t *testing.T) {
+	helmChart := testCRDChart()
+	helmChart.Templates = helmChart.Templates[2:]
+	crdChart, err := SplitCRDChart(helmChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crdChart == nil {
+		t.Fatal("expected CRD chart")
+	}
+	if names := chartFileNames(crdChart.Templates); !reflect.DeepEqual(names, []string{"templates/crd-manifest.yaml"}) {
+		t.Errorf("expected only the CRD manifest template, got %v", names)
+	}
+	if len(crdChart.Raw) != 0 || crdChart.Values != nil {
+		t.Error("expected values not to be copied without moved templates")
+	}
+}
+
+func TestSplitCRDChartWithoutCRDs(t *testing.T) {
+	helmChart := testCRDChart()
+	helmChart.Files = helmChart.Files[1:]
+	helmChart.Templates = helmChart.Templates[2:]
+	crdChart, err := SplitCRDChart(helmChart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crdChart != nil {
+		t.Errorf("expected no CRD chart, got %s", crdChart.Name())
+	}
+	if len(helmChart.Files) != 1 || len(helmChart.Templates) != 1 {
+		t.Error("expected chart without CRDs to be unchanged")
+	}
+}
+
+func TestIsCRDTemplate(t *testing.T) {
+	crd := "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\n"
+	tests := []struct {
+		name     string
+		template *chart.File
+		expected bool
+	}{
+		{"CRD", &chart.File{Name: "templates/crd.yaml", Data: []byte(crd)}, true},
+		{"quoted kind", &chart.File{Name: "templates/crd.yml", Data: []byte("kind: \"CustomResourceDefinition\"\n")}, true},
+		{"multiple CRDs", &chart.File{Name: "templates/crds.yaml", Data: []byte(crd + "---\n" + crd)}, true},
+		{"mixed kinds", &chart.File{Name: "templates/crds.yaml", Data: []byte(crd + "---\nkind: ConfigMap\n")}, false},
+		{"no kind", &chart.File{Name: "templates/empty.yaml", Data: []byte("{{- if .Values.enabled }}\n{{- end }}\n")}, false},
+		{"helper", &chart.File{Name: "templates/_crd.yaml", Data: []byte(crd)}, false},
+		{"not yaml", &chart.File{Name: "templates/NOTES.txt", Data: []byte(crd)}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if isCRD := isCRDTemplate(test.template); isCRD != test.expected {
+				t.Errorf("expected %t, got %t", test.expected, isCRD)
+			}
+		})
+	}
+}
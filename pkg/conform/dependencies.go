@@ -0,0 +1,152 @@
+package conform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/provenance"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	requirementsFile     = "requirements.yaml"
+	requirementsLockFile = "requirements.lock"
+)
+
+// Matches the digest calculated by Helm when resolving dependencies
+func hashDependencies(req, lock []*chart.Dependency) (string, error) {
+	data, err := json.Marshal([2][]*chart.Dependency{req, lock})
+	if err != nil {
+		return "", err
+	}
+	digest, err := provenance.Digest(bytes.NewBuffer(data))
+
+	return "sha256:" + digest, err
+}
+
+// Returns the embedded subchart of a dependency. Subcharts named after the
+// dependency alias are matched first, then those named after the dependency.
+// Returns nil if no subchart is embedded, or an error if no embedded subchart
+// satisfies the dependency version constraint
+func EmbeddedDependency(helmChart *chart.Chart, dependency *chart.Dependency) (*chart.Chart, error) {
+	candidates := make([]*chart.Chart, 0)
+	for _, name := range []string{dependency.Alias, dependency.Name} {
+		if name == "" {
+			continue
+		}
+		for _, subChart := range helmChart.Dependencies() {
+			if subChart.Name() == name {
+				candidates = append(candidates, subChart)
+			}
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	if dependency.Version == "" {
+		return candidates[0], nil
+	}
+
+	constraint, err := semver.NewConstraint(dependency.Version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint '%s' for dependency %s: %w", dependency.Version, dependency.Name, err)
+	}
+	for _, subChart := range candidates {
+		semVer, err := semver.NewVersion(subChart.Metadata.Version)
+		if err == nil && constraint.Check(semVer) {
+			return subChart, nil
+		}
+	}
+
+	return nil, fmt.Errorf("embedded dependency %s (%s) does not satisfy '%s'", candidates[0].Name(), candidates[0].Metadata.Version, dependency.Version)
+}
+
+// Replaces the content of a file in the chart, or adds it if not present
+func setChartFile(helmChart *chart.Chart, name string, data []byte) {
+	for _, file := range helmChart.Files {
+		if file.Name == name {
+			file.Data = data
+			return
+		}
+	}
+	helmChart.Files = append(helmChart.Files, &chart.File{Name: name, Data: data})
+}
+
+// Writes the dependencies and lock of an apiVersion v1 chart to
+// requirements.yaml and requirements.lock, as Helm only saves Chart.lock for
+// apiVersion v2 charts and restores v1 dependencies from requirements.yaml
+func writeRequirements(helmChart *chart.Chart) error {
+	requirements, err := yaml.Marshal(map[string][]*chart.Dependency{"dependencies": helmChart.Metadata.Dependencies})
+	if err != nil {
+		return err
+	}
+	setChartFile(helmChart, requirementsFile, requirements)
+
+	lock, err := yaml.Marshal(helmChart.Lock)
+	if err != nil {
+		return err
+	}
+	setChartFile(helmChart, requirementsLockFile, lock)
+
+	return nil
+}
+
+// Regenerates Chart.lock from the Chart.yaml dependencies and the versions of
+// the embedded subcharts. The existing lock is kept if unchanged. For
+// apiVersion v1 charts requirements.yaml and requirements.lock are updated
+func GenerateChartLock(helmChart *chart.Chart) error {
+	if len(helmChart.Metadata.Dependencies) == 0 {
+		helmChart.Lock = nil
+		return nil
+	}
+
+	locked := make([]*chart.Dependency, 0, len(helmChart.Metadata.Dependencies))
+	for _, dependency := range helmChart.Metadata.Dependencies {
+		subChart, err := EmbeddedDependency(helmChart, dependency)
+		if err != nil {
+			return fmt.Errorf("dependency %s of %s: %w", dependency.Name, helmChart.Name(), err)
+		}
+		if subChart == nil {
+			return fmt.Errorf("dependency %s of %s is not embedded", dependency.Name, helmChart.Name())
+		}
+		locked = append(locked, &chart.Dependency{
+			Name:       dependency.Name,
+			Repository: dependency.Repository,
+			Version:    subChart.Metadata.Version,
+		})
+	}
+
+	digest, err := hashDependencies(helmChart.Metadata.Dependencies, locked)
+	if err != nil {
+		return err
+	}
+
+	if helmChart.Lock == nil || helmChart.Lock.Digest != digest {
+		//Keep the upstream timestamp so that the archive is reproducible
+		generated := archiveModTime
+		if helmChart.Lock != nil {
+			generated = helmChart.Lock.Generated
+		}
+
+		helmChart.Lock = &chart.Lock{
+			Generated:    generated,
+			Digest:       digest,
+			Dependencies: locked,
+		}
+	}
+
+	if helmChart.Metadata.APIVersion == chart.APIVersionV1 {
+		return writeRequirements(helmChart)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package conform
+
+import (
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// Returns a chart with the given dependencies and embedded subcharts, keyed by
+// name and version
+func testDependencyChart(apiVersion string, dependencies []*chart.Dependency, subCharts map[string]string) *chart.Chart {
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:   apiVersion,
+			Name:         "parent",
+			Version:      "1.0.0",
+			Dependencies: dependencies,
+		},
+	}
+	for name, version := range subCharts {
+		helmChart.AddDependency(&chart.Chart{
+			Metadata: &chart.Metadata{APIVersion: apiVersion, Name: name, Version: version},
+		})
+	}
+
+	return helmChart
+}
+
+func TestEmbeddedDependency(t *testing.T) {
+	tests := []struct {
+		name       string
+		dependency *chart.Dependency
+		subCharts  map[string]string
+		expected   string
+		fails      bool
+	}{
+		{
+			name:       "matched by name",
+			dependency: &chart.Dependency{Name: "redis", Version: "~1.2.0"},
+			subCharts:  map[string]string{"redis": "1.2.3"},
+			expected:   "redis",
+		},
+		{
+			name:       "matched by alias",
+			dependency: &chart.Dependency{Name: "redis", Alias: "cache", Version: "^2.0.0"},
+			subCharts:  map[string]string{"redis": "1.2.3", "cache": "2.1.0"},
+			expected:   "cache",
+		},
+		{
+			name:       "aliased dependency embedded by name",
+			dependency: &chart.Dependency{Name: "redis", Alias: "cache", Version: "~1.2.0"},
+			subCharts:  map[string]string{"redis": "1.2.3"},
+			expected:   "redis",
+		},
+		{
+			name:       "not embedded",
+			dependency: &chart.Dependency{Name: "redis", Version: "~1.2.0"},
+			subCharts:  map[string]string{"postgresql": "1.2.3"},
+		},
+		{
+			name:       "unsatisfied constraint",
+			dependency: &chart.Dependency{Name: "redis", Version: "^2.0.0"},
+			subCharts:  map[string]string{"redis": "1.2.3"},
+			fails:      true,
+		},
+		{
+			name:       "unsatisfied constraint of alias",
+			dependency: &chart.Dependency{Name: "redis", Alias: "cache", Version: "^1.0.0"},
+			subCharts:  map[string]string{"redis": "1.2.3", "cache": "2.1.0"},
+			fails:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			helmChart := testDependencyChart(chart.APIVersionV2, []*chart.Dependency{test.dependency}, test.subCharts)
+			subChart, err := EmbeddedDependency(helmChart, test.dependency)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %v", subChart)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := ""
+			if subChart != nil {
+				name = subChart.Name()
+			}
+			if name != test.expected {
+				t.Errorf("expected '%s', got '%s'", test.expected, name)
+			}
+		})
+	}
+}
+
+func TestGenerateChartLockUnsatisfied(t *testing.T) {
+	helmChart := testDependencyChart(chart.APIVersionV2,
+		[]*chart.Dependency{{Name: "redis", Version: "^2.0.0"}},
+		map[string]string{"redis": "1.2.3"})
+
+	if err := GenerateChartLock(helmChart); err == nil {
+		t.Error("expected unsatisfied dependency to fail")
+	}
+}
+
+func TestGenerateChartLock(t *testing.T) {
+	for _, apiVersion := range []string{chart.APIVersionV1, chart.APIVersionV2} {
+		t.Run(apiVersion, func(t *testing.T) {
+			helmChart := testDependencyChart(apiVersion,
+				[]*chart.Dependency{{Name: "redis", Version: "~1.2.0", Repository: "https://charts.example.com"}},
+				map[string]string{"redis": "1.2.3"})
+			if apiVersion == chart.APIVersionV1 {
+				helmChart.Files = append(helmChart.Files,
+					&chart.File{Name: requirementsFile, Data: []byte("dependencies:\n- name: redis\n  version: ~1.2.0\n  repository: https://charts.example.com\n")},
+					&chart.File{Name: requirementsLockFile, Data: []byte("dependencies:\n- name: redis\n  version: 1.2.0\n  repository: https://charts.example.com\ndigest: sha256:stale\n")})
+			}
+			helmChart.Metadata.Dependencies[0].Repository = "file://./charts/redis"
+
+			if err := GenerateChartLock(helmChart); err != nil {
+				t.Fatal(err)
+			}
+
+			assetPath, err := chartutil.Save(helmChart, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := loader.Load(assetPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if loaded.Lock == nil || len(loaded.Lock.Dependencies) != 1 {
+				t.Fatalf("expected saved lock with one dependency, got %+v", loaded.Lock)
+			}
+			if loaded.Lock.Digest != helmChart.Lock.Digest {
+				t.Errorf("expected digest %s, got %s", helmChart.Lock.Digest, loaded.Lock.Digest)
+			}
+			if locked := loaded.Lock.Dependencies[0]; locked.Version != "1.2.3" || locked.Repository != "file://./charts/redis" {
+				t.Errorf("unexpected locked dependency %+v", locked)
+			}
+			if repository := loaded.Metadata.Dependencies[0].Repository; repository != "file://./charts/redis" {
+				t.Errorf("expected saved dependency repository file://./charts/redis, got %s", repository)
+			}
+
+			lockFiles := 0
+			for _, file := range loaded.Files {
+				if file.Name == requirementsLockFile {
+					lockFiles++
+					if strings.Contains(string(file.Data), "stale") {
+						t.Errorf("%s was not updated", requirementsLockFile)
+					}
+				}
+			}
+			if apiVersion == chart.APIVersionV1 && lockFiles != 1 {
+				t.Errorf("expected one %s, found %d", requirementsLockFile, lockFiles)
+			}
+			if apiVersion == chart.APIVersionV2 && lockFiles != 0 {
+				t.Errorf("expected no %s, found %d", requirementsLockFile, lockFiles)
+			}
+		})
+	}
+}
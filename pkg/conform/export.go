@@ -1,23 +1,35 @@
 package conform
 
 import (
-	"archive/tar"
-	"compress/gzip"
+	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"text/template"
 
+	"github.com/Masterminds/semver/v3"
+	"github.com/Masterminds/sprig/v3"
 	"github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
+
+	"sigs.k8s.io/yaml"
 )
 
 const (
-	overlayDir   = "overlay"
-	generatedDir = "generated-changes"
+	overlayDir                = "overlay"
+	overlayDeleteFile         = "overlay-delete.yaml"
+	overlayTemplateSuffix     = ".tmpl"
+	versionedOverlaySeparator = "@"
+	generatedDir              = "generated-changes"
+	generatedExcludeDir       = "exclude"
+	generatedPatchDir         = "patch"
+	generatedPatchSuffix      = ".patch"
 )
 
 func GetFileList(searchPath string, relative bool) ([]string, []string, error) {
@@ -51,117 +63,369 @@ func GetFileList(searchPath string, relative bool) ([]string, []string, error) {
 	return dirList, fileList, nil
 }
 
-func ApplyOverlayFiles(packagePath string) error {
-	overlayPath := filepath.Join(packagePath, overlayDir)
-	if _, err := os.Stat(overlayPath); !os.IsNotExist(err) {
-		dirList, fileList, err := GetFileList(overlayPath, true)
-		if err != nil {
-			return err
+// OverlayData is passed to overlay files ending in .tmpl when rendered
+type OverlayData struct {
+	//Name of the chart
+	Name string
+	//Version of the chart as published, including any encoded package version
+	Version string
+	//UpstreamVersion of the chart, used to match version scoped overlays
+	UpstreamVersion string
+	//AppVersion of the chart
+	AppVersion string
+	//Vendor providing the chart
+	Vendor string
+	//SourceURL the chart was fetched from
+	SourceURL string
+	//Values are the default values of the upstream chart
+	Values map[string]interface{}
+}
+
+// Renders an overlay template with the given data. Keys missing from Values
+// render as empty
+func renderOverlayTemplate(srcPath string, data OverlayData) ([]byte, error) {
+	templateFile, err := os.ReadFile(srcPath)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New(filepath.Base(srcPath)).
+		Funcs(sprig.TxtFuncMap()).
+		Option("missingkey=zero").
+		Parse(string(templateFile))
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err = t.Execute(buf, data); err != nil {
+		return nil, err
+	}
+
+	//Values missing from the chart render empty, as they do in Helm templates
+	return bytes.ReplaceAll(buf.Bytes(), []byte("<no value>"), nil), nil
+}
+
+func copyOverlayFile(srcPath, dstPath string) error {
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	if _, err = io.Copy(dstFile, srcFile); err != nil {
+		return err
+	}
+
+	return dstFile.Close()
+}
+
+// Copies the files of an overlay directory into the chart directory. Files
+// ending in .tmpl are rendered as Go templates with the given data and written
+// without the suffix
+func applyOverlayDir(overlayPath, chartPath string, data OverlayData) error {
+	dirList, fileList, err := GetFileList(overlayPath, true)
+	if err != nil {
+		return err
+	}
+	if len(dirList) == 0 {
+		dirList = append(dirList, "")
+	}
+	for _, dir := range dirList {
+		generatedPath := filepath.Join(chartPath, dir)
+		if _, err := os.Stat(generatedPath); os.IsNotExist(err) {
+			os.MkdirAll(generatedPath, 0755)
 		}
-		if len(dirList) == 0 {
-			dirList = append(dirList, "")
+	}
+
+	for _, filePath := range fileList {
+		srcPath := filepath.Join(overlayPath, filePath)
+		if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+			return err
 		}
-		for _, dir := range dirList {
-			generatedPath := filepath.Join(packagePath, "charts", dir)
-			if _, err := os.Stat(generatedPath); os.IsNotExist(err) {
-				os.MkdirAll(generatedPath, 0755)
-			}
+
+		isTemplate := strings.HasSuffix(filePath, overlayTemplateSuffix)
+		if isTemplate {
+			filePath = strings.TrimSuffix(filePath, overlayTemplateSuffix)
 		}
 
-		for _, filePath := range fileList {
-			srcPath := filepath.Join(overlayPath, filePath)
-			if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+		generatedPath := filepath.Join(chartPath, filePath)
+		if _, err := os.Stat(generatedPath); !os.IsNotExist(err) {
+			logrus.Warnf("Replacing %s with overlay file", filePath)
+			err = os.Remove(generatedPath)
+			if err != nil {
 				return err
 			}
+		}
 
-			srcFile, err := os.Open(srcPath)
+		if isTemplate {
+			rendered, err := renderOverlayTemplate(srcPath, data)
 			if err != nil {
-				return err
-			}
-			defer srcFile.Close()
-
-			generatedPath := filepath.Join(packagePath, "charts", filePath)
-			if _, err := os.Stat(generatedPath); !os.IsNotExist(err) {
-				logrus.Warnf("Replacing %s with overlay file", filePath)
-				err = os.Remove(generatedPath)
-				if err != nil {
-					return err
-				}
+				return fmt.Errorf("unable to render overlay template %s: %w", srcPath, err)
 			}
-			dstFile, err := os.Create(generatedPath)
+			err = os.WriteFile(generatedPath, rendered, 0644)
 			if err != nil {
 				return err
 			}
-			defer dstFile.Close()
+		} else if err := copyOverlayFile(srcPath, generatedPath); err != nil {
+			return err
+		}
+	}
 
-			if _, err = io.Copy(dstFile, srcFile); err != nil {
-				return err
-			}
+	return nil
+}
+
+// Returns the version scoped overlay directories, named overlay@<constraint>,
+// whose constraint is satisfied by the given chart version
+func versionedOverlayDirs(packagePath, version string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(packagePath, overlayDir+versionedOverlaySeparator+"*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	semVer, err := semver.NewVersion(version)
+	if err != nil {
+		if len(matches) > 0 {
+			logrus.Warnf("Unable to match versioned overlays for %s: %s\n", version, err)
+		}
+		return nil, nil
+	}
+
+	overlayDirs := make([]string, 0)
+	for _, match := range matches {
+		if info, err := os.Stat(match); err != nil || !info.IsDir() {
+			continue
+		}
+		constraintString := strings.TrimPrefix(filepath.Base(match), overlayDir+versionedOverlaySeparator)
+		constraint, err := semver.NewConstraint(constraintString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint in overlay directory %s: %w", match, err)
+		}
+		if constraint.Check(semVer) {
+			overlayDirs = append(overlayDirs, match)
 		}
+	}
 
+	return overlayDirs, nil
+}
+
+// Reads the list of glob patterns from overlay-delete.yaml, if present
+func readOverlayDeletions(packagePath string) ([]string, error) {
+	deletePath := filepath.Join(packagePath, overlayDeleteFile)
+	deleteFile, err := os.ReadFile(deletePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
 
-	return nil
+	patterns := make([]string, 0)
+	if err = yaml.Unmarshal(deleteFile, &patterns); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", deletePath, err)
+	}
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s' in %s: %w", pattern, deletePath, err)
+		}
+		if path.IsAbs(pattern) || pattern == ".." || strings.HasPrefix(pattern, "../") {
+			return nil, fmt.Errorf("pattern '%s' in %s must be relative to the chart", pattern, deletePath)
+		}
+	}
 
+	return patterns, nil
 }
 
-func LinkOverlayFiles(packagePath string) error {
-	overlayPath := filepath.Join(packagePath, overlayDir)
-	if _, err := os.Stat(overlayPath); !os.IsNotExist(err) {
-		dirList, fileList, err := GetFileList(overlayPath, true)
+// Removes chart files and directories matching the given glob patterns.
+// Returns the patterns that matched
+func deleteOverlayPaths(chartPath string, patterns []string) (map[string]bool, error) {
+	matched := make(map[string]bool)
+	walkFunc := func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if len(dirList) == 0 {
-			dirList = append(dirList, "")
+		if filePath == chartPath {
+			return nil
 		}
-		for _, dir := range dirList {
-			generatedPath := filepath.Join(packagePath, generatedDir, overlayDir, dir)
-			if _, err := os.Stat(generatedPath); os.IsNotExist(err) {
-				os.MkdirAll(generatedPath, 0755)
-			}
+		relativePath, err := filepath.Rel(chartPath, filePath)
+		if err != nil {
+			return err
 		}
+		relativePath = filepath.ToSlash(relativePath)
 
-		for _, file := range fileList {
-			depth := len(strings.Split(file, "/")) + 1
-			pathPrefix := strings.Repeat("../", depth)
-			generatedPath := filepath.Join(packagePath, generatedDir, overlayDir, file)
-			if _, err := os.Stat(generatedPath); !os.IsNotExist(err) {
-				err = os.Remove(generatedPath)
-				if err != nil {
-					logrus.Error(err)
-				}
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(path.Clean(pattern), relativePath); !ok {
+				continue
 			}
-			symLinkPath := filepath.Join(pathPrefix, overlayDir, file)
-			err = os.Symlink(symLinkPath, generatedPath)
-			if err != nil {
-				logrus.Error(err)
+			matched[pattern] = true
+			logrus.Debugf("Removing %s from chart\n", relativePath)
+			if err = os.RemoveAll(filePath); err != nil {
+				return err
 			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
+		return nil
 	}
 
-	return nil
+	if err := filepath.Walk(chartPath, walkFunc); err != nil {
+		return nil, err
+	}
+
+	return matched, nil
 }
 
-func RemoveOverlayFiles(packagePath string) error {
+// Removes chart files listed in overlay-delete.yaml, then copies the overlay
+// directory and any version scoped overlay directories matching the chart
+// version into the chart directory
+func ApplyOverlayFiles(packagePath string, data OverlayData) error {
+	chartPath := filepath.Join(packagePath, "charts")
+
+	patterns, err := readOverlayDeletions(packagePath)
+	if err != nil {
+		return err
+	}
+	if len(patterns) > 0 {
+		matched, err := deleteOverlayPaths(chartPath, patterns)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range patterns {
+			if !matched[pattern] {
+				logrus.Warnf("Pattern '%s' in %s did not match any chart files\n", pattern, overlayDeleteFile)
+			}
+		}
+	}
+
+	overlayDirs := make([]string, 0)
 	overlayPath := filepath.Join(packagePath, overlayDir)
 	if _, err := os.Stat(overlayPath); !os.IsNotExist(err) {
-		_, fileList, err := GetFileList(overlayPath, true)
+		overlayDirs = append(overlayDirs, overlayPath)
+	}
+
+	versionedDirs, err := versionedOverlayDirs(packagePath, data.UpstreamVersion)
+	if err != nil {
+		return err
+	}
+	overlayDirs = append(overlayDirs, versionedDirs...)
+
+	for _, dir := range overlayDirs {
+		logrus.Debugf("Applying overlay %s\n", dir)
+		if err = applyOverlayDir(dir, chartPath, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the chart paths written by the overlay directories of a package,
+// including the version scoped overlay directories of every version
+func overlayChartPaths(packagePath string) ([]string, error) {
+	overlayDirs, err := filepath.Glob(filepath.Join(packagePath, overlayDir+versionedOverlaySeparator+"*"))
+	if err != nil {
+		return nil, err
+	}
+	overlayDirs = append([]string{filepath.Join(packagePath, overlayDir)}, overlayDirs...)
+
+	chartPaths := make([]string, 0)
+	for _, dir := range overlayDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		_, fileList, err := GetFileList(dir, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, filePath := range fileList {
+			chartPaths = append(chartPaths, strings.TrimSuffix(filePath, overlayTemplateSuffix))
+		}
+	}
+
+	return chartPaths, nil
+}
+
+// Removes overlay files from the generated changes of a package. Overlays
+// are applied by ApplyOverlayFiles once the package is prepared, so overlay
+// files linked or captured by a patch would otherwise be applied unrendered
+// or to versions they do not match. Removes links into the overlay directory,
+// added and patched files written by overlays, and excluded files matching
+// overlay-delete.yaml
+func RemoveOverlayFiles(packagePath string) error {
+	generatedPath := filepath.Join(packagePath, generatedDir)
+	if _, err := os.Stat(generatedPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	generatedOverlayPath := filepath.Join(generatedPath, overlayDir)
+	walkFunc := func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(filePath)
 		if err != nil {
 			return err
 		}
-		for _, file := range fileList {
-			generatedPath := filepath.Join(packagePath, generatedDir, overlayDir, file)
-			if _, err := os.Stat(generatedPath); !os.IsNotExist(err) {
-				err = os.Remove(generatedPath)
-				if err != nil {
-					logrus.Error(err)
-				}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(filePath), target)
+		}
+		overlayPath := filepath.Join(packagePath, overlayDir)
+		if target != overlayPath && !strings.HasPrefix(target, overlayPath+string(filepath.Separator)) {
+			return nil
+		}
+		logrus.Debugf("Removing overlay link %s\n", filePath)
+
+		return os.Remove(filePath)
+	}
+	if _, err := os.Stat(generatedOverlayPath); !os.IsNotExist(err) {
+		if err = filepath.Walk(generatedOverlayPath, walkFunc); err != nil {
+			return err
+		}
+	}
+
+	chartPaths, err := overlayChartPaths(packagePath)
+	if err != nil {
+		return err
+	}
+	for _, chartPath := range chartPaths {
+		for _, generatedFile := range []string{
+			filepath.Join(generatedOverlayPath, chartPath),
+			filepath.Join(generatedPath, generatedPatchDir, chartPath+generatedPatchSuffix),
+		} {
+			if _, err := os.Lstat(generatedFile); os.IsNotExist(err) {
+				continue
+			}
+			logrus.Debugf("Removing overlay file %s from generated changes\n", generatedFile)
+			if err = os.Remove(generatedFile); err != nil {
+				return err
 			}
 		}
 	}
 
+	patterns, err := readOverlayDeletions(packagePath)
+	if err != nil {
+		return err
+	}
+	generatedExcludePath := filepath.Join(generatedPath, generatedExcludeDir)
+	if _, err := os.Stat(generatedExcludePath); len(patterns) > 0 && !os.IsNotExist(err) {
+		if _, err = deleteOverlayPaths(generatedExcludePath, patterns); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -186,7 +450,7 @@ func StandardizeChartDirectory(sourcePath string, targetPath string) error {
 }
 
 func ExportChartAsset(helmChart *chart.Chart, targetPath string) error {
-	_, err := chartutil.Save(helmChart, targetPath)
+	_, err := saveChartArchive(helmChart, targetPath)
 	if err != nil {
 		return err
 	}
@@ -205,7 +469,7 @@ func ExportChartDirectory(chart *chart.Chart, targetPath string) error {
 		return err
 	}
 
-	tgz, err := chartutil.Save(chart, tempDir)
+	tgz, err := saveChartArchive(chart, tempDir)
 	if err != nil {
 		err = fmt.Errorf("Unable to save chart archive to %s", tempDir)
 		return err
@@ -249,80 +513,3 @@ func ExportDependenciesToDirectory(chart *chart.Chart, targetPath string) error
 
 	return nil
 }
-
-func stripRootPath(path string) string {
-	newPath := filepath.ToSlash(path)
-	rootPath := strings.Split(newPath, "/")[0]
-	newPath = strings.TrimPrefix(newPath, "/")
-	newPath = strings.TrimPrefix(newPath, rootPath)
-	newPath = strings.TrimPrefix(newPath, "/")
-
-	return filepath.FromSlash(newPath)
-}
-
-func Gunzip(path string, outPath string) error {
-	if !strings.HasSuffix(path, ".tgz") && !strings.HasPrefix(path, ".gz") {
-		return fmt.Errorf("Expecting file of type .gz or .tgz")
-	}
-
-	if _, err := os.Stat(path); err != nil {
-		return fmt.Errorf("%s does not exist or is inaccessible", path)
-	}
-
-	gzipFile, err := os.Open(path)
-	if err != nil {
-		return err
-	}
-	defer gzipFile.Close()
-
-	gzipReader, err := gzip.NewReader(gzipFile)
-	if err != nil {
-		return err
-	}
-	defer gzipReader.Close()
-
-	tarReader := tar.NewReader(gzipReader)
-
-	for {
-		h, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
-
-		filePath := filepath.Join(outPath, stripRootPath(h.Name))
-		parentPath := filepath.Dir(filePath)
-		if _, err := os.Stat(parentPath); os.IsNotExist(err) {
-			if err = os.MkdirAll(parentPath, 0755); err != nil {
-				return err
-			}
-		}
-
-		if h.Typeflag == tar.TypeDir {
-			if err = os.MkdirAll(filePath, os.FileMode(h.Mode)); err != nil {
-				return err
-			}
-		} else if h.Typeflag == tar.TypeReg {
-			f, err := os.Create(filePath)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			if _, err = io.Copy(f, tarReader); err != nil {
-				return err
-			}
-
-			if err = os.Chmod(filePath, os.FileMode(h.Mode)); err != nil {
-				return err
-			}
-		} else if h.Name != "pax_global_header" {
-			return fmt.Errorf("unknown file type for %s", h.Name)
-		}
-
-	}
-
-	return nil
-}
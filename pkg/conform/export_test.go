@@ -0,0 +1,173 @@
+package conform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyOverlayFiles(t *testing.T) {
+	packagePath := t.TempDir()
+	writeTestFiles(t, packagePath, map[string]string{
+		"charts/Chart.yaml":                  "apiVersion: v2\nname: foo\nversion: 1.2.3\n",
+		"charts/README.md":                   "upstream\n",
+		"charts/templates/NOTES.txt":         "notes\n",
+		"charts/templates/tests/test.yaml":   "test\n",
+		overlayDeleteFile:                    "- templates/NOTES.txt\n- templates/tests\n",
+		"overlay/README.md":                  "overlay\n",
+		"overlay/app-readme.md.tmpl":         "{{ .Name }} {{ .Version }} {{ .Values.missing }}{{ dig \"image\" \"tag\" .AppVersion .Values }}\n",
+		"overlay@1.x/questions.yaml":         "v1\n",
+		"overlay@>=2.0.0/questions.yaml":     "v2\n",
+		"overlay@1.2.x/templates/extra.tmpl": "{{ .UpstreamVersion }}\n",
+	})
+
+	data := OverlayData{
+		Name:            "foo",
+		Version:         "1.2.301",
+		UpstreamVersion: "1.2.3",
+		AppVersion:      "0.1.0",
+		Values:          map[string]interface{}{},
+	}
+	if err := ApplyOverlayFiles(packagePath, data); err != nil {
+		t.Fatal(err)
+	}
+
+	chartPath := filepath.Join(packagePath, "charts")
+	expected := map[string]string{
+		"README.md":       "overlay\n",
+		"app-readme.md":   "foo 1.2.301 0.1.0\n",
+		"questions.yaml":  "v1\n",
+		"templates/extra": "1.2.3\n",
+		"Chart.yaml":      "apiVersion: v2\nname: foo\nversion: 1.2.3\n",
+	}
+	for name, content := range expected {
+		data, err := os.ReadFile(filepath.Join(chartPath, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("expected %s to contain %q, got %q", name, content, string(data))
+		}
+	}
+	for _, name := range []string{"app-readme.md.tmpl", "templates/NOTES.txt", "templates/tests"} {
+		if _, err := os.Stat(filepath.Join(chartPath, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be absent, got %v", name, err)
+		}
+	}
+}
+
+func TestRenderOverlayTemplateErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown field": "{{ .Missing }}",
+		"parse error":   "{{ .Name ",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			srcPath := filepath.Join(t.TempDir(), "file.tmpl")
+			writeTestFiles(t, filepath.Dir(srcPath), map[string]string{"file.tmpl": content})
+			if _, err := renderOverlayTemplate(srcPath, OverlayData{}); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRemoveOverlayFiles(t *testing.T) {
+	packagePath := t.TempDir()
+	writeTestFiles(t, packagePath, map[string]string{
+		"overlay/app-readme.md":                            "overlay\n",
+		"overlay/templates/extra.yaml":                     "overlay\n",
+		filepath.Join(generatedDir, overlayDir, "kept.md"): "generated\n",
+	})
+	generatedOverlayPath := filepath.Join(packagePath, generatedDir, overlayDir)
+	links := map[string]string{
+		"app-readme.md":        "../../overlay/app-readme.md",
+		"templates/extra.yaml": "../../../overlay/templates/extra.yaml",
+	}
+	for name, target := range links {
+		linkPath := filepath.Join(generatedOverlayPath, name)
+		if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, linkPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("kept.md", filepath.Join(generatedOverlayPath, "kept-link.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveOverlayFiles(packagePath); err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range links {
+		if _, err := os.Lstat(filepath.Join(generatedOverlayPath, name)); !os.IsNotExist(err) {
+			t.Errorf("expected link %s to be removed, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"kept.md", "kept-link.md"} {
+		if _, err := os.Lstat(filepath.Join(generatedOverlayPath, name)); err != nil {
+			t.Errorf("expected %s to be kept, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(packagePath, "overlay", "app-readme.md")); err != nil {
+		t.Errorf("expected overlay file to be kept, got %v", err)
+	}
+}
+
+// Overlays are applied to a prepared package, so a patch generated from it
+// captures overlay files as added, patched, and excluded chart files. The
+// generated changes below are those written for the prepared chart
+func TestRemoveOverlayFilesAfterPatch(t *testing.T) {
+	packagePath := t.TempDir()
+	writeTestFiles(t, packagePath, map[string]string{
+		"charts/Chart.yaml":              "apiVersion: v2\nname: foo\nversion: 1.2.3\n",
+		"charts/README.md":               "upstream\n",
+		"charts/templates/NOTES.txt":     "notes\n",
+		overlayDeleteFile:                "- templates/NOTES.txt\n",
+		"overlay/README.md":              "overlay\n",
+		"overlay/app-readme.md.tmpl":     "{{ .Name }} {{ .Version }}\n",
+		"overlay@2.x/templates/v2.yaml":  "v2\n",
+		"generated-changes/overlay/a.md": "edited\n",
+	})
+	data := OverlayData{Name: "foo", Version: "1.2.3", UpstreamVersion: "1.2.3"}
+	if err := ApplyOverlayFiles(packagePath, data); err != nil {
+		t.Fatal(err)
+	}
+
+	generatedPath := filepath.Join(packagePath, generatedDir)
+	chartPath := filepath.Join(packagePath, "charts")
+	captured := map[string]string{
+		"overlay/app-readme.md":       "app-readme.md",
+		"overlay/templates/v2.yaml":   "",
+		"patch/README.md.patch":       "",
+		"exclude/templates/NOTES.txt": "",
+	}
+	for generatedFile, chartFile := range captured {
+		content := "captured\n"
+		if chartFile != "" {
+			data, err := os.ReadFile(filepath.Join(chartPath, chartFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			content = string(data)
+		}
+		writeTestFiles(t, generatedPath, map[string]string{generatedFile: content})
+	}
+
+	if err := RemoveOverlayFiles(packagePath); err != nil {
+		t.Fatal(err)
+	}
+
+	for generatedFile := range captured {
+		if _, err := os.Lstat(filepath.Join(generatedPath, generatedFile)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed from generated changes, got %v", generatedFile, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(generatedPath, "overlay", "a.md")); err != nil {
+		t.Errorf("expected generated change not written by overlays to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,386 @@
+package conform
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	//LinkSkip skips symbolic and hard links in archives
+	LinkSkip = "skip"
+	//LinkReject fails extraction on symbolic and hard links in archives
+	LinkReject = "reject"
+	//LinkAllow extracts symbolic and hard links whose targets stay within the output directory
+	LinkAllow = "allow"
+)
+
+// ExtractOptions limits what is written when extracting an archive
+type ExtractOptions struct {
+	//MaxTotalSize is the maximum number of bytes extracted from the archive
+	MaxTotalSize int64
+	//MaxEntries is the maximum number of entries read from the archive
+	MaxEntries int
+	//LinkPolicy is one of LinkSkip, LinkReject, or LinkAllow
+	LinkPolicy string
+}
+
+// builtinExtractOptions are used for limits that are not configured
+var builtinExtractOptions = ExtractOptions{
+	MaxTotalSize: 100 << 20,
+	MaxEntries:   10000,
+	LinkPolicy:   LinkSkip,
+}
+
+// DefaultExtractOptions are used by Gunzip. Set with SetDefaultExtractOptions
+var DefaultExtractOptions = builtinExtractOptions
+
+// Sets the options used by Gunzip, as configured in configuration.yaml. Limits
+// and link policy that are not set keep their built-in defaults
+func SetDefaultExtractOptions(options ExtractOptions) error {
+	if options.MaxTotalSize < 0 || options.MaxEntries < 0 {
+		return fmt.Errorf("extract limits must not be negative")
+	}
+	switch options.LinkPolicy {
+	case "", LinkSkip, LinkReject, LinkAllow:
+	default:
+		return fmt.Errorf("unknown extract link policy '%s'", options.LinkPolicy)
+	}
+
+	DefaultExtractOptions = builtinExtractOptions
+	if options.MaxTotalSize > 0 {
+		DefaultExtractOptions.MaxTotalSize = options.MaxTotalSize
+	}
+	if options.MaxEntries > 0 {
+		DefaultExtractOptions.MaxEntries = options.MaxEntries
+	}
+	if options.LinkPolicy != "" {
+		DefaultExtractOptions.LinkPolicy = options.LinkPolicy
+	}
+
+	return nil
+}
+
+// ExtractError reports the archive entry that caused extraction to fail
+type ExtractError struct {
+	Entry string
+	Err   error
+}
+
+func (e *ExtractError) Error() string {
+	return fmt.Sprintf("archive entry '%s' rejected: %s", e.Entry, e.Err)
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	ErrUnsafePath      = errors.New("path escapes output directory")
+	ErrSizeLimit       = errors.New("archive exceeds size limit")
+	ErrEntryLimit      = errors.New("archive exceeds entry limit")
+	ErrLinkNotAllowed  = errors.New("links not allowed")
+	ErrUnsupportedType = errors.New("unsupported file type")
+)
+
+func stripRootPath(path string) string {
+	newPath := filepath.ToSlash(path)
+	rootPath := strings.Split(newPath, "/")[0]
+	newPath = strings.TrimPrefix(newPath, "/")
+	newPath = strings.TrimPrefix(newPath, rootPath)
+	newPath = strings.TrimPrefix(newPath, "/")
+
+	return filepath.FromSlash(newPath)
+}
+
+// Validates an archive entry name and returns its path relative to the output
+// directory, with the archive root directory removed
+func sanitizeEntryPath(name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, 0) {
+		return "", ErrUnsafePath
+	}
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(slashed) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" || (len(slashed) > 1 && slashed[1] == ':') {
+		return "", ErrUnsafePath
+	}
+	for _, element := range strings.Split(slashed, "/") {
+		if element == ".." {
+			return "", ErrUnsafePath
+		}
+	}
+
+	return stripRootPath(path.Clean(slashed)), nil
+}
+
+// Joins a relative path onto the output directory, ensuring that no existing
+// parent directory is a symbolic link
+func safeJoin(outPath, relativePath string) (string, error) {
+	target := filepath.Join(outPath, relativePath)
+	if !isWithin(outPath, target) {
+		return "", ErrUnsafePath
+	}
+	rel, err := filepath.Rel(outPath, target)
+	if err != nil {
+		return "", err
+	}
+
+	current := outPath
+	for _, element := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if element == "." || element == "" {
+			continue
+		}
+		current = filepath.Join(current, element)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", ErrUnsafePath
+		}
+	}
+
+	return target, nil
+}
+
+// Removes an existing symbolic link at the target so that it is not followed
+func removeExistingLink(target string) error {
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(target)
+	}
+
+	return nil
+}
+
+func writeRegularFile(target string, mode os.FileMode, reader io.Reader, limit int64) (int64, error) {
+	if err := removeExistingLink(target); err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	written, err := io.CopyN(f, reader, limit+1)
+	if err != nil && err != io.EOF {
+		return written, err
+	}
+	if written > limit {
+		return written, ErrSizeLimit
+	}
+
+	if err = f.Close(); err != nil {
+		return written, err
+	}
+
+	return written, os.Chmod(target, mode)
+}
+
+func copyExtractedFile(source, target string, limit int64) (int64, error) {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, ErrLinkNotAllowed
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	return writeRegularFile(target, info.Mode().Perm(), f, limit)
+}
+
+// Extracts a gzipped tar archive with DefaultExtractOptions
+func Gunzip(path string, outPath string) error {
+	return GunzipWithOptions(path, outPath, DefaultExtractOptions)
+}
+
+// Extracts a gzipped tar archive into outPath with its root directory removed.
+// Entries that would be written outside of outPath are rejected
+func GunzipWithOptions(path string, outPath string, options ExtractOptions) error {
+	if !strings.HasSuffix(path, ".tgz") && !strings.HasSuffix(path, ".gz") {
+		return fmt.Errorf("Expecting file of type .gz or .tgz")
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s does not exist or is inaccessible", path)
+	}
+
+	outPath, err := filepath.Abs(outPath)
+	if err != nil {
+		return err
+	}
+
+	gzipFile, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer gzipFile.Close()
+
+	gzipReader, err := gzip.NewReader(gzipFile)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+
+	entries := 0
+	remaining := options.MaxTotalSize
+	links := make(map[string]string)
+	for {
+		h, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if h.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		entries++
+		if options.MaxEntries > 0 && entries > options.MaxEntries {
+			return &ExtractError{Entry: h.Name, Err: ErrEntryLimit}
+		}
+
+		relativePath, err := sanitizeEntryPath(h.Name)
+		if err != nil {
+			return &ExtractError{Entry: h.Name, Err: err}
+		}
+		if relativePath == "" || relativePath == "." {
+			continue
+		}
+
+		filePath, err := safeJoin(outPath, relativePath)
+		if err != nil {
+			return &ExtractError{Entry: h.Name, Err: err}
+		}
+
+		if h.Typeflag != tar.TypeDir {
+			if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+				return &ExtractError{Entry: h.Name, Err: err}
+			}
+		}
+
+		limit := int64(math.MaxInt64 - 1)
+		if options.MaxTotalSize > 0 {
+			limit = remaining
+		}
+
+		var written int64
+		switch h.Typeflag {
+		case tar.TypeDir:
+			if err = removeExistingLink(filePath); err == nil {
+				err = os.MkdirAll(filePath, os.FileMode(h.Mode).Perm()|0700)
+			}
+		case tar.TypeReg:
+			if h.Size > limit {
+				return &ExtractError{Entry: h.Name, Err: ErrSizeLimit}
+			}
+			written, err = writeRegularFile(filePath, os.FileMode(h.Mode).Perm()|0600, tarReader, limit)
+		case tar.TypeSymlink, tar.TypeLink:
+			if options.LinkPolicy == LinkReject {
+				return &ExtractError{Entry: h.Name, Err: ErrLinkNotAllowed}
+			}
+			if options.LinkPolicy != LinkAllow {
+				logrus.Warnf("Skipping link %s in %s\n", h.Name, path)
+				continue
+			}
+			if h.Typeflag == tar.TypeSymlink {
+				err = extractSymlink(outPath, filePath, h.Linkname)
+				links[filePath] = h.Name
+			} else {
+				var linkTarget string
+				linkTarget, err = sanitizeEntryPath(h.Linkname)
+				if err == nil {
+					linkTarget, err = safeJoin(outPath, linkTarget)
+				}
+				if err == nil {
+					written, err = copyExtractedFile(linkTarget, filePath, limit)
+				}
+			}
+		default:
+			err = ErrUnsupportedType
+		}
+		if err != nil {
+			return &ExtractError{Entry: h.Name, Err: err}
+		}
+
+		remaining -= written
+	}
+
+	return verifySymlinks(outPath, links)
+}
+
+// Ensures extracted symbolic links resolve within the output directory once
+// all entries are written, removing any that do not
+func verifySymlinks(outPath string, links map[string]string) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	resolvedOutPath, err := filepath.EvalSymlinks(outPath)
+	if err != nil {
+		return err
+	}
+
+	for linkPath, entry := range links {
+		resolved, err := filepath.EvalSymlinks(linkPath)
+		if err == nil && !isWithin(resolvedOutPath, resolved) {
+			err = ErrUnsafePath
+		}
+		if err != nil {
+			os.Remove(linkPath)
+			return &ExtractError{Entry: entry, Err: err}
+		}
+	}
+
+	return nil
+}
+
+func isWithin(parentPath, targetPath string) bool {
+	rel, err := filepath.Rel(parentPath, targetPath)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Creates a symbolic link if its target resolves within the output directory
+func extractSymlink(outPath, filePath, linkName string) error {
+	if linkName == "" || filepath.IsAbs(linkName) || path.IsAbs(filepath.ToSlash(linkName)) {
+		return ErrUnsafePath
+	}
+
+	resolved := filepath.Join(filepath.Dir(filePath), filepath.FromSlash(linkName))
+	if !isWithin(outPath, resolved) {
+		return ErrUnsafePath
+	}
+
+	if err := removeExistingLink(filePath); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(filePath); err == nil {
+		return fmt.Errorf("%s already exists", filePath)
+	}
+
+	return os.Symlink(linkName, filePath)
+}
@@ -0,0 +1,254 @@
+package conform
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	body     string
+	size     int64
+}
+
+// Builds a gzipped tar archive from the given entries
+func buildArchive(t testing.TB, entries []tarEntry) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+			Mode:     0644,
+			Size:     int64(len(entry.body)),
+		}
+		if entry.size > 0 {
+			header.Size = entry.size
+		}
+		if entry.typeflag == tar.TypeDir {
+			header.Mode = 0755
+			header.Size = 0
+		}
+		if entry.typeflag == tar.TypeSymlink || entry.typeflag == tar.TypeLink {
+			header.Size = 0
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Size > 0 {
+			body := []byte(entry.body)
+			if entry.size > 0 {
+				body = bytes.Repeat([]byte("a"), int(entry.size))
+			}
+			if _, err := tarWriter.Write(body); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+var testExtractOptions = ExtractOptions{
+	MaxTotalSize: 1 << 10,
+	MaxEntries:   8,
+	LinkPolicy:   LinkAllow,
+}
+
+// Seed archives that must not write outside of the output directory
+func unsafeArchives(t testing.TB) map[string][]byte {
+	manyEntries := make([]tarEntry, 0, 10)
+	for i := 0; i < 10; i++ {
+		manyEntries = append(manyEntries, tarEntry{name: "chart/file" + strings.Repeat("x", i), typeflag: tar.TypeReg, body: "a"})
+	}
+
+	return map[string][]byte{
+		"path traversal": buildArchive(t, []tarEntry{
+			{name: "chart/../../evil", typeflag: tar.TypeReg, body: "evil"},
+		}),
+		"absolute path": buildArchive(t, []tarEntry{
+			{name: "/tmp/evil", typeflag: tar.TypeReg, body: "evil"},
+		}),
+		"symlink escape": buildArchive(t, []tarEntry{
+			{name: "chart/link", typeflag: tar.TypeSymlink, linkname: "../../.."},
+		}),
+		"absolute symlink": buildArchive(t, []tarEntry{
+			{name: "chart/link", typeflag: tar.TypeSymlink, linkname: "/tmp"},
+		}),
+		"write through symlink": buildArchive(t, []tarEntry{
+			{name: "chart/dir", typeflag: tar.TypeSymlink, linkname: "."},
+			{name: "chart/dir/../../evil", typeflag: tar.TypeReg, body: "evil"},
+		}),
+		"hardlink escape": buildArchive(t, []tarEntry{
+			{name: "chart/hardlink", typeflag: tar.TypeLink, linkname: "../../../etc/passwd"},
+		}),
+		"size limit": buildArchive(t, []tarEntry{
+			{name: "chart/large", typeflag: tar.TypeReg, size: 2 << 10},
+		}),
+		"entry limit": buildArchive(t, manyEntries),
+	}
+}
+
+// Returns the files written under root outside of allowedPath, other than
+// the archive itself
+func filesOutside(t *testing.T, root, allowedPath, archivePath string) []string {
+	outside := make([]string, 0)
+	err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filePath == root || filePath == archivePath || filePath == allowedPath || isWithin(allowedPath, filePath) {
+			return nil
+		}
+		outside = append(outside, filePath)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return outside
+}
+
+// Returns the symbolic links under outPath that resolve outside of it
+func escapingLinks(t *testing.T, outPath string) []string {
+	escaping := make([]string, 0)
+	resolvedOutPath, err := filepath.EvalSymlinks(outPath)
+	if err != nil {
+		return escaping
+	}
+	err = filepath.Walk(outPath, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		resolved, err := filepath.EvalSymlinks(filePath)
+		if err == nil && !isWithin(resolvedOutPath, resolved) {
+			escaping = append(escaping, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return escaping
+}
+
+// Extracts an archive into a subdirectory of a temporary directory and fails
+// if anything is written outside of it
+func extractContained(t *testing.T, data []byte, options ExtractOptions) error {
+	root := t.TempDir()
+	archivePath := filepath.Join(root, "chart.tgz")
+	if err := os.WriteFile(archivePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	outPath := filepath.Join(root, "nested", "out")
+
+	extractErr := GunzipWithOptions(archivePath, outPath, options)
+
+	if outside := filesOutside(t, root, filepath.Join(root, "nested"), archivePath); len(outside) > 0 {
+		t.Fatalf("files written outside of output directory: %s", strings.Join(outside, ", "))
+	}
+	if outside := filesOutside(t, filepath.Join(root, "nested"), outPath, archivePath); len(outside) > 0 {
+		t.Fatalf("files written outside of output directory: %s", strings.Join(outside, ", "))
+	}
+	if escaping := escapingLinks(t, outPath); len(escaping) > 0 {
+		t.Fatalf("links escape output directory: %s", strings.Join(escaping, ", "))
+	}
+
+	return extractErr
+}
+
+func TestGunzipRejectsUnsafeArchives(t *testing.T) {
+	expected := map[string]error{
+		"path traversal":        ErrUnsafePath,
+		"absolute path":         ErrUnsafePath,
+		"symlink escape":        ErrUnsafePath,
+		"absolute symlink":      ErrUnsafePath,
+		"write through symlink": ErrUnsafePath,
+		"hardlink escape":       ErrUnsafePath,
+		"size limit":            ErrSizeLimit,
+		"entry limit":           ErrEntryLimit,
+	}
+
+	for name, data := range unsafeArchives(t) {
+		t.Run(name, func(t *testing.T) {
+			err := extractContained(t, data, testExtractOptions)
+			if !errors.Is(err, expected[name]) {
+				t.Errorf("expected %v, got %v", expected[name], err)
+			}
+		})
+	}
+}
+
+func TestGunzipLinkPolicy(t *testing.T) {
+	data := buildArchive(t, []tarEntry{
+		{name: "chart/values.yaml", typeflag: tar.TypeReg, body: "a: b"},
+		{name: "chart/link.yaml", typeflag: tar.TypeSymlink, linkname: "values.yaml"},
+	})
+
+	if err := extractContained(t, data, ExtractOptions{LinkPolicy: LinkReject}); !errors.Is(err, ErrLinkNotAllowed) {
+		t.Errorf("expected %v with %s, got %v", ErrLinkNotAllowed, LinkReject, err)
+	}
+	if err := extractContained(t, data, ExtractOptions{LinkPolicy: LinkSkip}); err != nil {
+		t.Errorf("expected links to be skipped, got %v", err)
+	}
+	if err := extractContained(t, data, ExtractOptions{LinkPolicy: LinkAllow}); err != nil {
+		t.Errorf("expected contained link to be allowed, got %v", err)
+	}
+}
+
+func TestSetDefaultExtractOptions(t *testing.T) {
+	defer SetDefaultExtractOptions(ExtractOptions{})
+
+	if err := SetDefaultExtractOptions(ExtractOptions{MaxEntries: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if DefaultExtractOptions.MaxEntries != 5 || DefaultExtractOptions.MaxTotalSize != builtinExtractOptions.MaxTotalSize || DefaultExtractOptions.LinkPolicy != LinkSkip {
+		t.Errorf("unexpected options %+v", DefaultExtractOptions)
+	}
+	if err := SetDefaultExtractOptions(ExtractOptions{LinkPolicy: "follow"}); err == nil {
+		t.Error("expected unknown link policy to fail")
+	}
+	if err := SetDefaultExtractOptions(ExtractOptions{MaxTotalSize: -1}); err == nil {
+		t.Error("expected negative limit to fail")
+	}
+}
+
+func FuzzGunzip(f *testing.F) {
+	for _, data := range unsafeArchives(f) {
+		f.Add(data)
+	}
+	f.Add(buildArchive(f, []tarEntry{
+		{name: "chart/", typeflag: tar.TypeDir},
+		{name: "chart/Chart.yaml", typeflag: tar.TypeReg, body: "name: chart\nversion: 1.0.0\n"},
+		{name: "chart/templates/link.yaml", typeflag: tar.TypeSymlink, linkname: "../Chart.yaml"},
+		{name: "chart/copy.yaml", typeflag: tar.TypeLink, linkname: "chart/Chart.yaml"},
+	}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		extractContained(t, data, testExtractOptions)
+	})
+}
@@ -0,0 +1,155 @@
+package conform
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	//PackageVersionPatch encodes the package version into the patch number as patch*100+N
+	PackageVersionPatch = "patch"
+	//PackageVersionBuild appends the package version as rancher.N build metadata
+	PackageVersionBuild = "build"
+	//PackageVersionSuffix appends the package version as a rancher.N pre-release suffix
+	PackageVersionSuffix = "suffix"
+
+	packageVersionIdentifier = "rancher"
+)
+
+var (
+	PatchNumMultiplier = uint64(math.Pow10(2))
+	MaxPatchNum        = PatchNumMultiplier - 1
+)
+
+// Appends rancher.N to a dot separated list of semver identifiers
+func appendPackageIdentifier(identifiers string, packageVersion int) string {
+	identifier := fmt.Sprintf("%s.%d", packageVersionIdentifier, packageVersion)
+	if identifiers == "" {
+		return identifier
+	}
+
+	return identifiers + "." + identifier
+}
+
+// Removes a trailing rancher.N from a dot separated list of semver identifiers.
+// Returns false if not present
+func trimPackageIdentifier(identifiers string) (string, int, bool) {
+	split := strings.Split(identifiers, ".")
+	if len(split) < 2 || split[len(split)-2] != packageVersionIdentifier {
+		return identifiers, 0, false
+	}
+
+	packageVersion, err := strconv.Atoi(split[len(split)-1])
+	if err != nil {
+		return identifiers, 0, false
+	}
+
+	return strings.Join(split[:len(split)-2], "."), packageVersion, true
+}
+
+// Encodes a package version into an upstream chart version using the given
+// format. Defaults to PackageVersionPatch if format is empty
+func EncodePackageVersion(upstreamChartVersion string, packageVersion int, format string) (string, error) {
+	chartVersion, err := semver.NewVersion(upstreamChartVersion)
+	if err != nil {
+		return "", err
+	}
+	if packageVersion < 0 {
+		return "", fmt.Errorf("package version %d must not be negative", packageVersion)
+	}
+
+	switch format {
+	case "", PackageVersionPatch:
+		if uint64(packageVersion) > MaxPatchNum {
+			return "", fmt.Errorf("package version %d is greater than maximum of %d", packageVersion, MaxPatchNum)
+		}
+		if chartVersion.Patch() > (math.MaxUint64-MaxPatchNum)/PatchNumMultiplier {
+			return "", fmt.Errorf("patch number of %s is too large to encode package version", upstreamChartVersion)
+		}
+		patchVersion := PatchNumMultiplier*chartVersion.Patch() + uint64(packageVersion)
+		chartVersion = semver.New(chartVersion.Major(), chartVersion.Minor(), patchVersion, chartVersion.Prerelease(), chartVersion.Metadata())
+	case PackageVersionBuild:
+		chartVersion = semver.New(chartVersion.Major(), chartVersion.Minor(), chartVersion.Patch(), chartVersion.Prerelease(),
+			appendPackageIdentifier(chartVersion.Metadata(), packageVersion))
+	case PackageVersionSuffix:
+		chartVersion = semver.New(chartVersion.Major(), chartVersion.Minor(), chartVersion.Patch(),
+			appendPackageIdentifier(chartVersion.Prerelease(), packageVersion), chartVersion.Metadata())
+	default:
+		return "", fmt.Errorf("unknown package version format '%s'", format)
+	}
+
+	return chartVersion.String(), nil
+}
+
+// Decodes a chart version into the upstream chart version and package version
+// using the given format. Defaults to PackageVersionPatch if format is empty.
+// Versions without an encoded package version in the build or suffix formats
+// are returned with a package version of 0
+func DecodePackageVersion(chartVersion string, format string) (string, int, error) {
+	version, err := semver.NewVersion(chartVersion)
+	if err != nil {
+		return "", 0, err
+	}
+
+	switch format {
+	case "", PackageVersionPatch:
+		packageVersion := version.Patch() % PatchNumMultiplier
+		patchVersion := version.Patch() / PatchNumMultiplier
+		version = semver.New(version.Major(), version.Minor(), patchVersion, version.Prerelease(), version.Metadata())
+		return version.String(), int(packageVersion), nil
+	case PackageVersionBuild:
+		if metadata, packageVersion, ok := trimPackageIdentifier(version.Metadata()); ok {
+			version = semver.New(version.Major(), version.Minor(), version.Patch(), version.Prerelease(), metadata)
+			return version.String(), packageVersion, nil
+		}
+		return version.String(), 0, nil
+	case PackageVersionSuffix:
+		if prerelease, packageVersion, ok := trimPackageIdentifier(version.Prerelease()); ok {
+			version = semver.New(version.Major(), version.Minor(), version.Patch(), prerelease, version.Metadata())
+			return version.String(), packageVersion, nil
+		}
+		return version.String(), 0, nil
+	}
+
+	return "", 0, fmt.Errorf("unknown package version format '%s'", format)
+}
+
+// Returns the upstream chart version of a version stored by a package. The
+// version is only decoded if the package encodes a package version, using the
+// package's format. Unparsable versions are returned unchanged
+func StripPackageVersion(chartVersion string, packageVersion int, format string) string {
+	if packageVersion == 0 {
+		return chartVersion
+	}
+
+	upstreamChartVersion, _, err := DecodePackageVersion(chartVersion, format)
+	if err != nil {
+		logrus.Error(err)
+		return chartVersion
+	}
+
+	return upstreamChartVersion
+}
+
+// Returns the version to store a chart as. A fixed version takes precedence,
+// otherwise the package version, if set, is encoded in the given format
+func GeneratePackageVersion(upstreamChartVersion string, packageVersion *int, version string, format string) (string, error) {
+	if version != "" {
+		return version, nil
+	}
+	if packageVersion != nil {
+		return EncodePackageVersion(upstreamChartVersion, *packageVersion, format)
+	}
+
+	chartVersion, err := semver.NewVersion(upstreamChartVersion)
+	if err != nil {
+		return "", err
+	}
+
+	return chartVersion.String(), nil
+}
@@ -0,0 +1,105 @@
+package conform
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+func TestPackageVersionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name           string
+		upstream       string
+		packageVersion int
+		format         string
+		encoded        string
+	}{
+		{"default format", "1.2.3", 1, "", "1.2.301"},
+		{"patch", "1.2.3", 1, PackageVersionPatch, "1.2.301"},
+		{"patch zero", "1.2.0", 5, PackageVersionPatch, "1.2.5"},
+		{"patch maximum", "1.2.3", 99, PackageVersionPatch, "1.2.399"},
+		{"patch with prerelease and metadata", "1.2.3-beta.1+git.abc", 2, PackageVersionPatch, "1.2.302-beta.1+git.abc"},
+		{"patch with v prefix", "v1.2.3", 1, PackageVersionPatch, "1.2.301"},
+		{"build", "1.2.3", 1, PackageVersionBuild, "1.2.3+rancher.1"},
+		{"build with metadata", "1.2.3+git.abc", 4, PackageVersionBuild, "1.2.3+git.abc.rancher.4"},
+		{"build with prerelease", "1.2.3-rc.1", 1, PackageVersionBuild, "1.2.3-rc.1+rancher.1"},
+		{"suffix", "1.2.3", 1, PackageVersionSuffix, "1.2.3-rancher.1"},
+		{"suffix with prerelease", "1.2.3-rc.1", 2, PackageVersionSuffix, "1.2.3-rc.1.rancher.2"},
+		{"suffix with metadata", "1.2.3+git.abc", 3, PackageVersionSuffix, "1.2.3-rancher.3+git.abc"},
+		{"suffix zero", "1.2.3", 0, PackageVersionSuffix, "1.2.3-rancher.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded, err := EncodePackageVersion(test.upstream, test.packageVersion, test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encoded != test.encoded {
+				t.Fatalf("encoded %s as %s, expected %s", test.upstream, encoded, test.encoded)
+			}
+
+			decoded, packageVersion, err := DecodePackageVersion(encoded, test.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := semver.MustParse(test.upstream).String(); decoded != expected {
+				t.Errorf("decoded %s as %s, expected %s", encoded, decoded, expected)
+			}
+			if packageVersion != test.packageVersion {
+				t.Errorf("decoded package version %d from %s, expected %d", packageVersion, encoded, test.packageVersion)
+			}
+		})
+	}
+}
+
+func TestEncodePackageVersionErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		upstream       string
+		packageVersion int
+		format         string
+	}{
+		{"package version too large", "1.2.3", 100, PackageVersionPatch},
+		{"negative package version", "1.2.3", -1, PackageVersionPatch},
+		{"patch overflow", "1.2.999999999999999999", 1, PackageVersionPatch},
+		{"unknown format", "1.2.3", 1, "other"},
+		{"invalid version", "not-a-version", 1, PackageVersionPatch},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if encoded, err := EncodePackageVersion(test.upstream, test.packageVersion, test.format); err == nil {
+				t.Errorf("expected error, encoded %s", encoded)
+			}
+		})
+	}
+}
+
+func TestStripPackageVersion(t *testing.T) {
+	tests := []struct {
+		name           string
+		stored         string
+		packageVersion int
+		format         string
+		expected       string
+	}{
+		{"unencoded large patch", "1.2.300", 0, "", "1.2.300"},
+		{"unencoded large patch with build format", "1.2.300", 1, PackageVersionBuild, "1.2.300"},
+		{"encoded patch", "1.2.301", 1, "", "1.2.3"},
+		{"encoded patch with earlier package version", "1.2.301", 2, PackageVersionPatch, "1.2.3"},
+		{"encoded build", "1.2.3+rancher.1", 1, PackageVersionBuild, "1.2.3"},
+		{"build not decoded as patch", "1.2.3+rancher.1", 1, PackageVersionPatch, "1.2.0+rancher.1"},
+		{"encoded suffix", "1.2.3-rancher.2", 2, PackageVersionSuffix, "1.2.3"},
+		{"suffix without identifier", "1.2.3-rc.1", 1, PackageVersionSuffix, "1.2.3-rc.1"},
+		{"unparsable", "latest", 1, "", "latest"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if stripped := StripPackageVersion(test.stored, test.packageVersion, test.format); stripped != test.expected {
+				t.Errorf("stripped %s as %s, expected %s", test.stored, stripped, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package conform
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	AppReadmeFile = "app-readme.md"
+	readmeFile    = "README.md"
+	//GeneratedAppReadmeMarker is prepended to app-readme files generated from the chart README
+	GeneratedAppReadmeMarker = "<!-- generated from README.md by partner-charts-ci -->"
+	//DefaultAppReadmeLength is the maximum length of a generated app-readme when none is configured
+	DefaultAppReadmeLength = 500
+)
+
+var (
+	readmeHeadingRegex = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	readmeBadgeRegex   = regexp.MustCompile(`\[!\[[^\]]*\]\([^)]*\)\]\([^)]*\)|!\[[^\]]*\]\([^)]*\)`)
+	readmeCommentRegex = regexp.MustCompile(`(?s)<!--.*?-->`)
+	readmeHtmlRegex    = regexp.MustCompile(`<[^>]+>`)
+	readmeSkipRegex    = regexp.MustCompile(`^\s*([-*+|>]|\d+\.|\[.*\]:)`)
+)
+
+func getChartFile(helmChart *chart.Chart, fileName string) *chart.File {
+	for _, f := range helmChart.Files {
+		if strings.EqualFold(f.Name, fileName) {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// Returns true if the chart app-readme was generated from the chart README
+func IsGeneratedAppReadme(helmChart *chart.Chart) bool {
+	appReadme := getChartFile(helmChart, AppReadmeFile)
+	if appReadme == nil {
+		return false
+	}
+
+	return strings.HasPrefix(string(appReadme.Data), GeneratedAppReadmeMarker)
+}
+
+// Splits markdown into the prose paragraphs of each section, dropping
+// headings, code blocks, lists, tables, badges, and HTML
+func readmeSections(readme string) [][]string {
+	sections := make([][]string, 0)
+	paragraphs := make([]string, 0)
+	paragraph := make([]string, 0)
+	inCodeBlock := false
+
+	endParagraph := func() {
+		if len(paragraph) > 0 {
+			paragraphs = append(paragraphs, strings.Join(paragraph, " "))
+			paragraph = make([]string, 0)
+		}
+	}
+	endSection := func() {
+		endParagraph()
+		if len(paragraphs) > 0 {
+			sections = append(sections, paragraphs)
+			paragraphs = make([]string, 0)
+		}
+	}
+
+	readme = readmeCommentRegex.ReplaceAllString(readme, "")
+	for _, line := range strings.Split(readme, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCodeBlock = !inCodeBlock
+			endParagraph()
+			continue
+		}
+		if inCodeBlock || strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if readmeHeadingRegex.MatchString(line) {
+			endSection()
+			continue
+		}
+
+		//A line of = or - below a paragraph underlines a heading
+		if len(paragraph) > 0 && trimmed != "" && strings.Trim(trimmed, "=-") == "" {
+			paragraph = make([]string, 0)
+			endSection()
+			continue
+		}
+
+		trimmed = readmeBadgeRegex.ReplaceAllString(trimmed, "")
+		trimmed = readmeHtmlRegex.ReplaceAllString(trimmed, "")
+		trimmed = strings.TrimSpace(trimmed)
+		if trimmed == "" || readmeSkipRegex.MatchString(trimmed) || strings.Trim(trimmed, "=-") == "" {
+			endParagraph()
+			continue
+		}
+
+		paragraph = append(paragraph, trimmed)
+	}
+	endSection()
+
+	return sections
+}
+
+// Shortens text to maxLength characters, breaking on a word where possible
+func truncateText(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+
+	truncated := string(runes[:maxLength])
+	if i := strings.LastIndexAny(truncated, " \n"); i > 0 {
+		truncated = truncated[:i]
+	}
+
+	return strings.TrimRight(truncated, " \n.,;:") + "..."
+}
+
+// Generates an app-readme from the first meaningful section of the chart README
+// if the chart does not already provide one. Returns true if generated
+func GenerateAppReadme(helmChart *chart.Chart, maxLength int) bool {
+	if getChartFile(helmChart, AppReadmeFile) != nil {
+		return false
+	}
+
+	readme := getChartFile(helmChart, readmeFile)
+	if readme == nil {
+		logrus.Debugf("No %s found for %s (%s)\n", readmeFile, helmChart.Name(), helmChart.Metadata.Version)
+		return false
+	}
+
+	if maxLength <= 0 {
+		maxLength = DefaultAppReadmeLength
+	}
+
+	sections := readmeSections(string(readme.Data))
+	if len(sections) == 0 {
+		logrus.Debugf("No usable content in %s for %s (%s)\n", readmeFile, helmChart.Name(), helmChart.Metadata.Version)
+		return false
+	}
+
+	content := truncateText(strings.Join(sections[0], "\n\n"), maxLength)
+	appReadme := &chart.File{
+		Name: AppReadmeFile,
+		Data: []byte(GeneratedAppReadmeMarker + "\n" + content + "\n"),
+	}
+	helmChart.Files = append(helmChart.Files, appReadme)
+
+	return true
+}
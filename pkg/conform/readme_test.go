@@ -0,0 +1,169 @@
+package conform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func testReadmeChart(files map[string]string) *chart.Chart {
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "foo", Version: "1.2.3"},
+	}
+	for name, content := range files {
+		helmChart.Files = append(helmChart.Files, &chart.File{Name: name, Data: []byte(content)})
+	}
+
+	return helmChart
+}
+
+// Returns the generated app-readme without the generated marker
+func generatedAppReadme(t *testing.T, helmChart *chart.Chart) string {
+	t.Helper()
+	appReadme := getChartFile(helmChart, AppReadmeFile)
+	if appReadme == nil {
+		t.Fatal("expected app-readme to be generated")
+	}
+	if !IsGeneratedAppReadme(helmChart) {
+		t.Error("expected app-readme to be marked as generated")
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(string(appReadme.Data), GeneratedAppReadmeMarker+"\n"), "\n")
+}
+
+func TestReadmeSections(t *testing.T) {
+	readme := `[![Build](https://example.com/badge.svg)](https://example.com)
+# Foo
+
+<!-- description -->
+Foo deploys <b>foo</b>
+on Kubernetes.
+
+Second paragraph.
+
+` + "```" + `
+helm install foo
+` + "```" + `
+
+    indented code
+
+- list item
+| table |
+
+Heading
+=======
+
+## Install
+Install foo.
+`
+	expected := [][]string{
+		{"Foo deploys foo on Kubernetes.", "Second paragraph."},
+		{"Install foo."},
+	}
+	if sections := readmeSections(readme); !reflect.DeepEqual(sections, expected) {
+		t.Errorf("expected %q, got %q", expected, sections)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		expected  string
+	}{
+		{"short", "Foo deploys foo.", 20, "Foo deploys foo."},
+		{"exact", "Foo deploys foo.", 16, "Foo deploys foo."},
+		{"word boundary", "Foo deploys foo on Kubernetes.", 14, "Foo deploys..."},
+		{"trailing punctuation", "Foo, deploys foo.", 5, "Foo..."},
+		{"single word", "Foodeploysfoo", 4, "Food..."},
+		{"multibyte", "Föö déploys föö", 9, "Föö..."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if truncated := truncateText(test.text, test.maxLength); truncated != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, truncated)
+			}
+		})
+	}
+}
+
+func TestGenerateAppReadme(t *testing.T) {
+	longParagraph := strings.Repeat("word ", 200)
+	tests := []struct {
+		name      string
+		readme    string
+		maxLength int
+		expected  string
+	}{
+		{
+			name:     "first section",
+			readme:   "# Foo\nFoo deploys foo.\n\nIt is fast.\n\n## Install\nInstall foo.\n",
+			expected: "Foo deploys foo.\n\nIt is fast.",
+		},
+		{
+			name:      "truncated at word",
+			readme:    "# Foo\nFoo deploys foo on Kubernetes.\n",
+			maxLength: 20,
+			expected:  "Foo deploys foo on...",
+		},
+		{
+			name:      "truncated at section",
+			readme:    "# Foo\nFoo.\n\n## Install\n" + longParagraph,
+			maxLength: 100,
+			expected:  "Foo.",
+		},
+		{
+			name:     "default length",
+			readme:   longParagraph,
+			expected: truncateText(strings.TrimSpace(longParagraph), DefaultAppReadmeLength),
+		},
+		{
+			name:      "negative length",
+			readme:    longParagraph,
+			maxLength: -1,
+			expected:  truncateText(strings.TrimSpace(longParagraph), DefaultAppReadmeLength),
+		},
+		{
+			name:     "no headings",
+			readme:   "Foo deploys foo.\n\nIt is fast.\n",
+			expected: "Foo deploys foo.\n\nIt is fast.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			helmChart := testReadmeChart(map[string]string{"README.md": test.readme})
+			if !GenerateAppReadme(helmChart, test.maxLength) {
+				t.Fatal("expected app-readme to be generated")
+			}
+			if appReadme := generatedAppReadme(t, helmChart); appReadme != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, appReadme)
+			}
+		})
+	}
+}
+
+func TestGenerateAppReadmeSkipped(t *testing.T) {
+	tests := map[string]map[string]string{
+		"app-readme provided": {"README.md": "Foo.\n", "app-readme.md": "Provided.\n"},
+		"no README":           {},
+		"no prose":            {"README.md": "# Foo\n- item\n\n```\ncode\n```\n"},
+	}
+
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			helmChart := testReadmeChart(files)
+			filesCount := len(helmChart.Files)
+			if GenerateAppReadme(helmChart, 0) {
+				t.Error("expected app-readme not to be generated")
+			}
+			if len(helmChart.Files) != filesCount || IsGeneratedAppReadme(helmChart) {
+				t.Error("expected chart files to be unchanged")
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package conform
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+const defaultNamespace = "default"
+
+// Manifest is a single Kubernetes resource rendered from a chart
+type Manifest struct {
+	//Source is the chart file the resource was read from
+	Source string
+	Object map[string]interface{}
+}
+
+func (manifest Manifest) APIVersion() string {
+	apiVersion, _ := manifest.Object["apiVersion"].(string)
+	return apiVersion
+}
+
+func (manifest Manifest) Kind() string {
+	kind, _ := manifest.Object["kind"].(string)
+	return kind
+}
+
+func (manifest Manifest) Name() string {
+	metadata, _ := manifest.Object["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	return name
+}
+
+// Returns the Capabilities to render against. Defaults to the Helm default
+// Kubernetes version if kubeVersion is empty. API versions removed before the
+// given Kubernetes version are not included
+func renderCapabilities(kubeVersion string) (*chartutil.Capabilities, error) {
+	capabilities := chartutil.DefaultCapabilities.Copy()
+	if kubeVersion != "" {
+		parsedKubeVersion, err := chartutil.ParseKubeVersion(kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+		capabilities.KubeVersion = *parsedKubeVersion
+
+		minor, err := KubeMinorVersion(kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+		capabilities.APIVersions = removedAPIVersions(capabilities.APIVersions, minor)
+	}
+
+	return capabilities, nil
+}
+
+// Renders chart templates offline with default values
+func RenderChart(helmChart *chart.Chart, namespace, kubeVersion string) (map[string]string, error) {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	capabilities, err := renderCapabilities(kubeVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      helmChart.Name(),
+		Namespace: namespace,
+		Revision:  1,
+		IsInstall: true,
+	}
+
+	values, err := chartutil.ToRenderValues(helmChart, map[string]interface{}{}, releaseOptions, capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	return engine.Render(helmChart, values)
+}
+
+// Parses rendered templates and chart CRD files into individual resources,
+// ordered by source file
+func ParseManifests(helmChart *chart.Chart, rendered map[string]string) []Manifest {
+	sources := make(map[string]string)
+	for fileName, content := range rendered {
+		if ext := path.Ext(fileName); ext == ".yaml" || ext == ".yml" || ext == ".json" {
+			sources[fileName] = content
+		}
+	}
+	for _, crd := range helmChart.CRDObjects() {
+		sources[crd.Filename] = string(crd.File.Data)
+	}
+
+	sourceNames := make([]string, 0, len(sources))
+	for sourceName := range sources {
+		sourceNames = append(sourceNames, sourceName)
+	}
+	sort.Strings(sourceNames)
+
+	manifests := make([]Manifest, 0)
+	for _, sourceName := range sourceNames {
+		documents := releaseutil.SplitManifests(sources[sourceName])
+		documentNames := make([]string, 0, len(documents))
+		for documentName := range documents {
+			documentNames = append(documentNames, documentName)
+		}
+		sort.Sort(releaseutil.BySplitManifestsOrder(documentNames))
+
+		for _, documentName := range documentNames {
+			document := documents[documentName]
+			if strings.TrimSpace(document) == "" {
+				continue
+			}
+			object := make(map[string]interface{})
+			if err := yaml.Unmarshal([]byte(document), &object); err != nil {
+				logrus.Debugf("Unable to parse resource in %s: %s\n", sourceName, err)
+				continue
+			}
+			if len(object) == 0 {
+				continue
+			}
+			manifests = append(manifests, Manifest{
+				Source: sourceName,
+				Object: object,
+			})
+		}
+	}
+
+	return manifests
+}
@@ -0,0 +1,202 @@
+package conform
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	nodeSelectorOS     = "kubernetes.io/os"
+	nodeSelectorBetaOS = "beta.kubernetes.io/os"
+)
+
+// ChartRequirements represents cluster requirements inferred from chart content
+type ChartRequirements struct {
+	//ProvidesGVR lists provided custom resources as <group>.<kind>/<version>
+	ProvidesGVR []string
+	//RequestsCPU is the total CPU requested by workloads rendered with default values
+	RequestsCPU string
+	//RequestsMemory is the total memory requested by workloads rendered with default values
+	RequestsMemory string
+	//PermitsOS lists the operating system every workload is restricted to by
+	//node selector. Empty if any workload is unrestricted or workloads differ
+	PermitsOS []string
+}
+
+func nestedMap(object map[string]interface{}, fields ...string) map[string]interface{} {
+	current := object
+	for _, field := range fields {
+		next, ok := current[field].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = next
+	}
+
+	return current
+}
+
+func nestedSlice(object map[string]interface{}, fields ...string) []interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	parent := nestedMap(object, fields[:len(fields)-1]...)
+	if parent == nil {
+		return nil
+	}
+	slice, _ := parent[fields[len(fields)-1]].([]interface{})
+
+	return slice
+}
+
+// Returns the pod spec of a workload resource and the number of replicas it runs
+func PodSpec(manifest Manifest) (map[string]interface{}, int64) {
+	replicas := int64(1)
+	switch manifest.Kind() {
+	case "Pod":
+		return nestedMap(manifest.Object, "spec"), replicas
+	case "Deployment", "StatefulSet", "ReplicaSet", "ReplicationController":
+		if value, ok := nestedMap(manifest.Object, "spec")["replicas"].(float64); ok {
+			replicas = int64(value)
+		}
+		return nestedMap(manifest.Object, "spec", "template", "spec"), replicas
+	case "DaemonSet", "Job":
+		return nestedMap(manifest.Object, "spec", "template", "spec"), replicas
+	case "CronJob":
+		return nestedMap(manifest.Object, "spec", "jobTemplate", "spec", "template", "spec"), replicas
+	}
+
+	return nil, 0
+}
+
+func parseQuantity(value interface{}) (resource.Quantity, error) {
+	switch v := value.(type) {
+	case string:
+		return resource.ParseQuantity(v)
+	case float64:
+		return resource.ParseQuantity(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	return resource.Quantity{}, fmt.Errorf("invalid quantity %v", value)
+}
+
+// Returns the group, kind, and preferred version of a CustomResourceDefinition
+func crdGVR(manifest Manifest) (string, error) {
+	spec := nestedMap(manifest.Object, "spec")
+	group, _ := spec["group"].(string)
+	kind, _ := nestedMap(spec, "names")["kind"].(string)
+	if group == "" || kind == "" {
+		return "", fmt.Errorf("missing group or kind")
+	}
+
+	version, _ := spec["version"].(string)
+	for _, v := range nestedSlice(spec, "versions") {
+		crdVersion, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := crdVersion["name"].(string)
+		if storage, _ := crdVersion["storage"].(bool); storage {
+			version = name
+			break
+		}
+		if served, _ := crdVersion["served"].(bool); served && version == "" {
+			version = name
+		}
+	}
+	if version == "" {
+		return "", fmt.Errorf("no served version")
+	}
+
+	return fmt.Sprintf("%s.%s/%s", group, strings.ToLower(kind), version), nil
+}
+
+// Infers provided resources, resource requests, and permitted operating
+// systems from rendered chart resources
+func InferChartRequirements(manifests []Manifest) ChartRequirements {
+	requirements := ChartRequirements{}
+	providedSet := make(map[string]struct{})
+	osSet := make(map[string]struct{})
+	unrestrictedOS := false
+	cpu := resource.Quantity{}
+	memory := resource.Quantity{}
+
+	for _, manifest := range manifests {
+		if manifest.Kind() == "CustomResourceDefinition" {
+			gvr, err := crdGVR(manifest)
+			if err != nil {
+				logrus.Debugf("Unable to read CRD %s in %s: %s\n", manifest.Name(), manifest.Source, err)
+				continue
+			}
+			providedSet[gvr] = struct{}{}
+			continue
+		}
+
+		spec, replicas := PodSpec(manifest)
+		if spec == nil {
+			continue
+		}
+
+		nodeSelector := nestedMap(spec, "nodeSelector")
+		workloadOS := ""
+		for _, label := range []string{nodeSelectorOS, nodeSelectorBetaOS} {
+			if operatingSystem, ok := nodeSelector[label].(string); ok && operatingSystem != "" {
+				workloadOS = operatingSystem
+				break
+			}
+		}
+		if workloadOS != "" {
+			osSet[workloadOS] = struct{}{}
+		} else {
+			unrestrictedOS = true
+		}
+
+		for _, c := range nestedSlice(spec, "containers") {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			requests := nestedMap(container, "resources", "requests")
+			for name, total := range map[string]*resource.Quantity{"cpu": &cpu, "memory": &memory} {
+				value, ok := requests[name]
+				if !ok {
+					continue
+				}
+				quantity, err := parseQuantity(value)
+				if err != nil {
+					logrus.Debugf("Invalid %s request in %s: %s\n", name, manifest.Source, err)
+					continue
+				}
+				total.Add(*resource.NewMilliQuantity(quantity.MilliValue()*replicas, quantity.Format))
+			}
+		}
+	}
+
+	for gvr := range providedSet {
+		requirements.ProvidesGVR = append(requirements.ProvidesGVR, gvr)
+	}
+	sort.Strings(requirements.ProvidesGVR)
+
+	//The chart is only restricted to an operating system if every workload is
+	if len(osSet) == 1 && !unrestrictedOS {
+		for operatingSystem := range osSet {
+			requirements.PermitsOS = []string{operatingSystem}
+		}
+	}
+
+	if !cpu.IsZero() {
+		requirements.RequestsCPU = fmt.Sprintf("%dm", cpu.MilliValue())
+	}
+	if !memory.IsZero() {
+		mebibytes := (memory.Value() + (1<<20 - 1)) >> 20
+		requirements.RequestsMemory = fmt.Sprintf("%dMi", mebibytes)
+	}
+
+	return requirements
+}
@@ -0,0 +1,138 @@
+package conform
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// Returns a workload manifest of the given kind running a container for each
+// of the given requests, as <cpu>/<memory> with either empty if not requested
+func testWorkload(kind string, replicas int, nodeSelector string, requests ...string) string {
+	containers := ""
+	for i, request := range requests {
+		containers += fmt.Sprintf("      - name: c%d\n", i)
+		cpu, memory, _ := strings.Cut(request, "/")
+		if cpu == "" && memory == "" {
+			continue
+		}
+		containers += "        resources:\n          requests:\n"
+		if cpu != "" {
+			containers += fmt.Sprintf("            cpu: %s\n", cpu)
+		}
+		if memory != "" {
+			containers += fmt.Sprintf("            memory: %s\n", memory)
+		}
+	}
+	selector := ""
+	if nodeSelector != "" {
+		selector = fmt.Sprintf("      nodeSelector:\n        %s\n", nodeSelector)
+	}
+
+	return fmt.Sprintf("apiVersion: apps/v1\nkind: %s\nmetadata:\n  name: foo\nspec:\n  replicas: %d\n  template:\n    spec:\n%s      containers:\n%s",
+		kind, replicas, selector, containers)
+}
+
+func TestInferChartRequirements(t *testing.T) {
+	crd := `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  names:
+    kind: Foo
+  versions:
+  - name: v1alpha1
+    served: true
+  - name: v1
+    served: true
+    storage: true
+`
+	tests := []struct {
+		name      string
+		manifests []string
+		expected  ChartRequirements
+	}{
+		{
+			name:      "replicas",
+			manifests: []string{testWorkload("Deployment", 3, "", "100m/64Mi", "50m/")},
+			expected:  ChartRequirements{RequestsCPU: "450m", RequestsMemory: "192Mi"},
+		},
+		{
+			name:      "many replicas",
+			manifests: []string{testWorkload("StatefulSet", 1000000, "", "1/1Gi")},
+			expected:  ChartRequirements{RequestsCPU: "1000000000m", RequestsMemory: "1024000000Mi"},
+		},
+		{
+			name:      "no replicas",
+			manifests: []string{testWorkload("Deployment", 0, "", "100m/64Mi")},
+			expected:  ChartRequirements{},
+		},
+		{
+			name:      "replicas ignored for daemonsets",
+			manifests: []string{testWorkload("DaemonSet", 3, "", "100m/100M")},
+			expected:  ChartRequirements{RequestsCPU: "100m", RequestsMemory: "96Mi"},
+		},
+		{
+			name:      "missing requests",
+			manifests: []string{testWorkload("Deployment", 2, "", "", "/32Mi")},
+			expected:  ChartRequirements{RequestsMemory: "64Mi"},
+		},
+		{
+			name:      "invalid request",
+			manifests: []string{testWorkload("Deployment", 1, "", "lots/32Mi")},
+			expected:  ChartRequirements{RequestsMemory: "32Mi"},
+		},
+		{
+			name: "same node selectors",
+			manifests: []string{
+				testWorkload("Deployment", 1, "kubernetes.io/os: linux", ""),
+				testWorkload("DaemonSet", 1, "beta.kubernetes.io/os: linux", ""),
+			},
+			expected: ChartRequirements{PermitsOS: []string{"linux"}},
+		},
+		{
+			name: "conflicting node selectors",
+			manifests: []string{
+				testWorkload("Deployment", 1, "kubernetes.io/os: linux", ""),
+				testWorkload("DaemonSet", 1, "kubernetes.io/os: windows", ""),
+			},
+			expected: ChartRequirements{},
+		},
+		{
+			name: "unrestricted workload",
+			manifests: []string{
+				testWorkload("Deployment", 1, "kubernetes.io/os: linux", ""),
+				testWorkload("Deployment", 1, "kubernetes.io/arch: amd64", ""),
+			},
+			expected: ChartRequirements{},
+		},
+		{
+			name:      "provided resources",
+			manifests: []string{crd, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n"},
+			expected:  ChartRequirements{ProvidesGVR: []string{"example.com.foo/v1"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rendered := make(map[string]string)
+			for i, manifest := range test.manifests {
+				rendered[fmt.Sprintf("foo/templates/%d.yaml", i)] = manifest
+			}
+			manifests := ParseManifests(&chart.Chart{Metadata: &chart.Metadata{Name: "foo"}}, rendered)
+			if len(manifests) != len(test.manifests) {
+				t.Fatalf("expected %d manifests, got %d", len(test.manifests), len(manifests))
+			}
+
+			requirements := InferChartRequirements(manifests)
+			if !reflect.DeepEqual(requirements, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, requirements)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package fetcher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Selects the newest version satisfying the dependency version constraint.
+// Pre-release versions are only considered when no constraint is given
+func matchDependencyVersion(constraint string, versions []string) (string, error) {
+	var versionConstraint *semver.Constraints
+	var err error
+	if constraint != "" {
+		versionConstraint, err = semver.NewConstraint(constraint)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var matched *semver.Version
+	for _, version := range versions {
+		semVer, err := semver.NewVersion(version)
+		if err != nil {
+			logrus.Debugf("%s: %s", version, err)
+			continue
+		}
+		if versionConstraint == nil && semVer.Prerelease() != "" {
+			continue
+		}
+		if versionConstraint != nil && !versionConstraint.Check(semVer) {
+			continue
+		}
+		if matched == nil || semVer.GreaterThan(matched) {
+			matched = semVer
+		}
+	}
+
+	if matched == nil {
+		return "", fmt.Errorf("no version matching '%s' found", constraint)
+	}
+
+	return matched.Original(), nil
+}
+
+// Fetches dependency chart from Helm repository
+func fetchHelmRepoDependency(dependency *chart.Dependency) (*chart.Chart, error) {
+	repoUrl := strings.TrimSuffix(dependency.Repository, "/")
+	resp, err := http.Get(fmt.Sprintf("%s/index.yaml", repoUrl))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch index from %s: %s", repoUrl, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	indexYaml := repo.NewIndexFile()
+	err = yaml.Unmarshal(body, indexYaml)
+	if err != nil {
+		return nil, err
+	}
+
+	chartVersions, ok := indexYaml.Entries[dependency.Name]
+	if !ok {
+		return nil, fmt.Errorf("chart %s not found in %s", dependency.Name, repoUrl)
+	}
+
+	versions := make([]string, 0, len(chartVersions))
+	for _, chartVersion := range chartVersions {
+		versions = append(versions, chartVersion.Version)
+	}
+
+	version, err := matchDependencyVersion(dependency.Version, versions)
+	if err != nil {
+		return nil, err
+	}
+
+	chartVersion, err := indexYaml.Get(dependency.Name, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(chartVersion.URLs) == 0 {
+		return nil, fmt.Errorf("no URL listed for %s (%s) in %s", dependency.Name, version, repoUrl)
+	}
+
+	chartUrl := chartVersion.URLs[0]
+	if !strings.HasPrefix(chartUrl, "http") {
+		chartUrl = repoUrl + "/" + chartUrl
+	}
+
+	return LoadChartFromUrl(chartUrl)
+}
+
+// Fetches dependency chart from OCI registry
+func fetchOCIDependency(dependency *chart.Dependency) (*chart.Chart, error) {
+	registryClient, err := registry.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ref := fmt.Sprintf("%s/%s", strings.TrimPrefix(strings.TrimSuffix(dependency.Repository, "/"), "oci://"), dependency.Name)
+	tags, err := registryClient.Tags(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := matchDependencyVersion(dependency.Version, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	pullResult, err := registryClient.Pull(fmt.Sprintf("%s:%s", ref, strings.ReplaceAll(version, "+", "_")))
+	if err != nil {
+		return nil, err
+	}
+
+	return loader.LoadArchive(bytes.NewReader(pullResult.Chart.Data))
+}
+
+// Downloads any dependency declared in Chart.yaml that is not embedded in the
+// chart archive and adds it to the chart. Fails if an embedded dependency does
+// not satisfy its version constraint
+func VendorDependencies(helmChart *chart.Chart) error {
+	for _, dependency := range helmChart.Metadata.Dependencies {
+		subChart, err := conform.EmbeddedDependency(helmChart, dependency)
+		if err != nil {
+			return fmt.Errorf("dependency %s of %s: %w", dependency.Name, helmChart.Name(), err)
+		}
+		if subChart != nil {
+			continue
+		}
+
+		logrus.Infof("Vendoring dependency %s (%s) from %s\n", dependency.Name, dependency.Version, dependency.Repository)
+		switch {
+		case strings.HasPrefix(dependency.Repository, "http://"), strings.HasPrefix(dependency.Repository, "https://"):
+			subChart, err = fetchHelmRepoDependency(dependency)
+		case strings.HasPrefix(dependency.Repository, "oci://"):
+			subChart, err = fetchOCIDependency(dependency)
+		case dependency.Repository == "", strings.HasPrefix(dependency.Repository, "file://"):
+			err = fmt.Errorf("not found in chart archive")
+		default:
+			err = fmt.Errorf("unsupported repository '%s'", dependency.Repository)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to resolve dependency %s (%s) of %s: %w", dependency.Name, dependency.Version, helmChart.Name(), err)
+		}
+		if subChart.Name() != dependency.Name {
+			return fmt.Errorf("dependency %s of %s resolved to chart %s", dependency.Name, helmChart.Name(), subChart.Name())
+		}
+
+		helmChart.AddDependency(subChart)
+	}
+
+	return nil
+}
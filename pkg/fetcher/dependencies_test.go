@@ -0,0 +1,69 @@
+package fetcher
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestMatchDependencyVersion(t *testing.T) {
+	versions := []string{"1.0.0", "1.2.0", "1.2.3", "2.0.0-rc.1", "v1.3.0", "invalid"}
+	tests := []struct {
+		constraint string
+		expected   string
+		fails      bool
+	}{
+		{constraint: "", expected: "v1.3.0"},
+		{constraint: "~1.2.0", expected: "1.2.3"},
+		{constraint: ">=2.0.0-0", expected: "2.0.0-rc.1"},
+		{constraint: "^3.0.0", fails: true},
+		{constraint: "not a constraint", fails: true},
+	}
+
+	for _, test := range tests {
+		version, err := matchDependencyVersion(test.constraint, versions)
+		if test.fails {
+			if err == nil {
+				t.Errorf("'%s': expected error, got %s", test.constraint, version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("'%s': %s", test.constraint, err)
+		} else if version != test.expected {
+			t.Errorf("'%s': expected %s, got %s", test.constraint, test.expected, version)
+		}
+	}
+}
+
+func TestVendorDependenciesEmbedded(t *testing.T) {
+	tests := []struct {
+		name       string
+		dependency *chart.Dependency
+		fails      bool
+	}{
+		{name: "satisfied", dependency: &chart.Dependency{Name: "redis", Version: "~1.2.0", Repository: "https://charts.example.com"}},
+		{name: "satisfied by alias", dependency: &chart.Dependency{Name: "redis", Alias: "cache", Version: "~1.2.0", Repository: "https://charts.example.com"}},
+		{name: "unsatisfied", dependency: &chart.Dependency{Name: "redis", Version: "^2.0.0", Repository: "https://charts.example.com"}, fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			helmChart := &chart.Chart{
+				Metadata: &chart.Metadata{Name: "parent", Version: "1.0.0", Dependencies: []*chart.Dependency{test.dependency}},
+			}
+			helmChart.AddDependency(&chart.Chart{Metadata: &chart.Metadata{Name: "redis", Version: "1.2.3"}})
+
+			err := VendorDependencies(helmChart)
+			if test.fails && err == nil {
+				t.Error("expected embedded dependency not satisfying its constraint to fail")
+			}
+			if !test.fails && err != nil {
+				t.Error(err)
+			}
+			if len(helmChart.Dependencies()) != 1 {
+				t.Errorf("expected no dependency to be vendored, found %d", len(helmChart.Dependencies()))
+			}
+		})
+	}
+}
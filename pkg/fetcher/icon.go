@@ -0,0 +1,199 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	//DefaultIconMaxSize is the maximum icon size in bytes if not configured
+	DefaultIconMaxSize = 256 << 10
+	//MaxIconDimension is the maximum width and height of raster icons in pixels
+	MaxIconDimension = 2048
+)
+
+// iconTimeout is the time allowed to download an icon
+var iconTimeout = 30 * time.Second
+
+// iconExtensions maps supported icon content types to file extensions
+var iconExtensions = map[string]string{
+	"image/png":     "png",
+	"image/jpeg":    "jpg",
+	"image/gif":     "gif",
+	"image/svg+xml": "svg",
+}
+
+// Icon is a chart icon that has been read and validated
+type Icon struct {
+	Data        []byte
+	ContentType string
+}
+
+// Extension returns the file extension for the icon content type
+func (icon Icon) Extension() string {
+	return iconExtensions[icon.ContentType]
+}
+
+// DataURI returns the icon encoded as a base64 data URI
+func (icon Icon) DataURI() string {
+	return fmt.Sprintf("data:%s;base64,%s", icon.ContentType, base64.StdEncoding.EncodeToString(icon.Data))
+}
+
+// Ensures an SVG document has an svg root element and no scripts
+func validateSVG(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	root := true
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid SVG: %w", err)
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		name := strings.ToLower(element.Name.Local)
+		if root && name != "svg" {
+			return fmt.Errorf("invalid SVG: root element is %s", element.Name.Local)
+		}
+		root = false
+		if name == "script" || name == "foreignobject" {
+			return fmt.Errorf("SVG contains %s element", element.Name.Local)
+		}
+		for _, attr := range element.Attr {
+			if strings.HasPrefix(strings.ToLower(attr.Name.Local), "on") {
+				return fmt.Errorf("SVG contains event handler %s", attr.Name.Local)
+			}
+			//Browsers ignore whitespace and control characters in URL schemes
+			value := strings.ToLower(strings.Map(func(r rune) rune {
+				if r <= ' ' {
+					return -1
+				}
+				return r
+			}, attr.Value))
+			if strings.HasPrefix(value, "javascript:") {
+				return fmt.Errorf("SVG contains script URL in %s", attr.Name.Local)
+			}
+		}
+	}
+	if root {
+		return fmt.Errorf("invalid SVG: no svg element")
+	}
+
+	return nil
+}
+
+// Detects the icon content type and validates its format and size
+func ReadIcon(data []byte, maxSize int64) (*Icon, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultIconMaxSize
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("icon is empty")
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("icon size %d bytes exceeds maximum of %d", len(data), maxSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := iconExtensions[contentType]; !ok {
+		trimmed := bytes.TrimSpace(data)
+		if !bytes.HasPrefix(trimmed, []byte("<")) {
+			return nil, fmt.Errorf("unsupported icon type %s", contentType)
+		}
+		contentType = "image/svg+xml"
+	}
+
+	if contentType == "image/svg+xml" {
+		if err := validateSVG(data); err != nil {
+			return nil, err
+		}
+	} else {
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s icon: %w", contentType, err)
+		}
+		if config.Width == 0 || config.Height == 0 || config.Width > MaxIconDimension || config.Height > MaxIconDimension {
+			return nil, fmt.Errorf("icon dimensions %dx%d outside of 1x1 to %dx%d",
+				config.Width, config.Height, MaxIconDimension, MaxIconDimension)
+		}
+	}
+
+	return &Icon{
+		Data:        data,
+		ContentType: contentType,
+	}, nil
+}
+
+// Decodes a data URI
+func decodeDataURI(dataURI string) ([]byte, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(dataURI, "data:"), ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data URI")
+	}
+	if strings.HasSuffix(header, ";base64") {
+		return base64.StdEncoding.DecodeString(payload)
+	}
+	decoded, err := url.PathUnescape(payload)
+
+	return []byte(decoded), err
+}
+
+// Downloads an icon from an http(s) URL or decodes a data URI, then validates it
+func FetchIcon(iconURL string, maxSize int64) (*Icon, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultIconMaxSize
+	}
+
+	if strings.HasPrefix(iconURL, "data:") {
+		data, err := decodeDataURI(iconURL)
+		if err != nil {
+			return nil, err
+		}
+		return ReadIcon(data, maxSize)
+	}
+
+	parsedURL, err := url.Parse(iconURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported icon URL %s", iconURL)
+	}
+
+	logrus.Debugf("Fetching icon from %s\n", iconURL)
+	client := http.Client{Timeout: iconTimeout}
+	resp, err := client.Get(iconURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch icon %s: %s", iconURL, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadIcon(data, maxSize)
+}
@@ -0,0 +1,168 @@
+package fetcher
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSVG = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="16" height="16">
+  <a xlink:href="https://example.com"><rect width="16" height="16"/></a>
+</svg>
+`
+
+// Returns a raster icon of the given content type and dimensions
+func testRasterIcon(t *testing.T, contentType string, width, height int) []byte {
+	t.Helper()
+	img := image.NewPaletted(image.Rect(0, 0, width, height), []color.Color{color.Black, color.White})
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/png":
+		err = png.Encode(&buf, img)
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "image/gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		t.Fatalf("unsupported test icon type %s", contentType)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestReadIcon(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{"png", testRasterIcon(t, "image/png", 16, 16), "image/png"},
+		{"jpeg", testRasterIcon(t, "image/jpeg", 16, 16), "image/jpeg"},
+		{"gif", testRasterIcon(t, "image/gif", 16, 16), "image/gif"},
+		{"svg", []byte(testSVG), "image/svg+xml"},
+		{"svg without declaration", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><path d="M0 0h1"/></svg>`), "image/svg+xml"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			icon, err := ReadIcon(test.data, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if icon.ContentType != test.contentType {
+				t.Errorf("expected %s, got %s", test.contentType, icon.ContentType)
+			}
+			if icon.Extension() == "" {
+				t.Errorf("expected extension for %s", icon.ContentType)
+			}
+		})
+	}
+}
+
+func TestReadIconInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int64
+	}{
+		{"empty", nil, 0},
+		{"too large", testRasterIcon(t, "image/png", 16, 16), 16},
+		{"too wide", testRasterIcon(t, "image/png", MaxIconDimension+1, 1), 0},
+		{"text", []byte("not an icon"), 0},
+		{"pdf", []byte("%PDF-1.4\n"), 0},
+		{"truncated png", testRasterIcon(t, "image/png", 16, 16)[:32], 0},
+		{"html", []byte("<html><body>icon</body></html>"), 0},
+		{"svg script", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), 0},
+		{"svg foreign object", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><foreignObject/></svg>`), 0},
+		{"svg event handler", []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"/>`), 0},
+		{"svg javascript href", []byte(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><a xlink:href="javascript:alert(1)"><rect/></a></svg>`), 0},
+		{"svg obfuscated javascript href", []byte(`<svg xmlns="http://www.w3.org/2000/svg"><a href=" Java&#x09;Script:alert(1)"><rect/></a></svg>`), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if icon, err := ReadIcon(test.data, test.maxSize); err == nil {
+				t.Errorf("expected error, got %s icon", icon.ContentType)
+			}
+		})
+	}
+}
+
+func TestFetchIcon(t *testing.T) {
+	pngIcon := testRasterIcon(t, "image/png", 16, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/icon.png":
+			w.Write(pngIcon)
+		case "/icon.svg":
+			//The served content type is not trusted
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(testSVG))
+		case "/script.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`))
+		case "/slow.png":
+			time.Sleep(200 * time.Millisecond)
+			w.Write(pngIcon)
+		case "/large.png":
+			w.Write(append(pngIcon, make([]byte, DefaultIconMaxSize)...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	timeout := iconTimeout
+	iconTimeout = 50 * time.Millisecond
+	defer func() { iconTimeout = timeout }()
+
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		fails       bool
+	}{
+		{name: "png", url: server.URL + "/icon.png", contentType: "image/png"},
+		{name: "svg", url: server.URL + "/icon.svg", contentType: "image/svg+xml"},
+		{name: "base64 data URI", url: "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngIcon), contentType: "image/png"},
+		{name: "data URI", url: "data:image/svg+xml," + strings.ReplaceAll(testSVG, "#", "%23"), contentType: "image/svg+xml"},
+		{name: "not found", url: server.URL + "/missing.png", fails: true},
+		{name: "timeout", url: server.URL + "/slow.png", fails: true},
+		{name: "script", url: server.URL + "/script.svg", fails: true},
+		{name: "too large", url: server.URL + "/large.png", fails: true},
+		{name: "unsupported scheme", url: "file:///icon.png", fails: true},
+		{name: "invalid data URI", url: "data:image/png;base64", fails: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			icon, err := FetchIcon(test.url, 0)
+			if test.fails {
+				if err == nil {
+					t.Errorf("expected error, got %s icon", icon.ContentType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if icon.ContentType != test.contentType {
+				t.Errorf("expected %s, got %s", test.contentType, icon.ContentType)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
+	"github.com/samuelattwood/partner-charts-ci/pkg/validate"
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+const (
+	//IssueMissingAsset is an index entry without a matching asset
+	IssueMissingAsset = "missing-asset"
+	//IssueDigestMismatch is an index entry with a digest that does not match its asset
+	IssueDigestMismatch = "digest-mismatch"
+	//IssueUnindexedAsset is an asset without an index entry
+	IssueUnindexedAsset = "unindexed-asset"
+	//IssueInvalidAsset is an asset that cannot be loaded as a chart
+	IssueInvalidAsset = "invalid-asset"
+	//IssueMissingChart is a chart with assets but no chart directory
+	IssueMissingChart = "missing-chart"
+	//IssueStaleChart is a chart directory that does not match the latest asset
+	IssueStaleChart = "stale-chart"
+	//IssueOrphanedChart is a chart directory without assets
+	IssueOrphanedChart = "orphaned-chart"
+	//IssueMissingShard is a vendor index shard that has not been written
+	IssueMissingShard = "missing-shard"
+	//IssueStaleShard is a vendor index shard that does not match the index
+	IssueStaleShard = "stale-shard"
+	//IssueOrphanedShard is a vendor index shard without charts in the index
+	IssueOrphanedShard = "orphaned-shard"
+)
+
+// Issue is an inconsistency between the index, assets and chart directories
+type Issue struct {
+	Kind string
+	//Path is the asset, chart directory, or index shard the issue was found for
+	Path    string
+	Message string
+	//Chart and Version identify the index entry, if any
+	Chart   string
+	Version string
+	//Asset is the asset the issue is fixed from, if any
+	Asset string
+}
+
+func (issue Issue) String() string {
+	return fmt.Sprintf("[%s] %s: %s", issue.Kind, issue.Path, issue.Message)
+}
+
+// storedAsset is a chart asset found in the assets directory
+type storedAsset struct {
+	path    string
+	vendor  string
+	name    string
+	version *semver.Version
+	digest  string
+}
+
+// Loads every chart asset in the assets directory and its vendor
+// subdirectories, as indexed by repo.IndexDirectory
+func (manager *Manager) listAssets() ([]storedAsset, []Issue, error) {
+	archives, err := filepath.Glob(filepath.Join(manager.assetsPath, "*.tgz"))
+	if err != nil {
+		return nil, nil, err
+	}
+	vendorArchives, err := filepath.Glob(filepath.Join(manager.assetsPath, "*", "*.tgz"))
+	if err != nil {
+		return nil, nil, err
+	}
+	archives = append(archives, vendorArchives...)
+	sort.Strings(archives)
+
+	assets := make([]storedAsset, 0, len(archives))
+	issues := make([]Issue, 0)
+	for _, archive := range archives {
+		helmChart, err := loader.Load(archive)
+		if err != nil {
+			issues = append(issues, Issue{
+				Kind:    IssueInvalidAsset,
+				Path:    manager.relativePath(archive),
+				Message: err.Error(),
+			})
+			continue
+		}
+		digest, err := provenance.DigestFile(archive)
+		if err != nil {
+			return nil, nil, err
+		}
+		chartVersion, err := semver.NewVersion(helmChart.Metadata.Version)
+		if err != nil {
+			issues = append(issues, Issue{
+				Kind:    IssueInvalidAsset,
+				Path:    manager.relativePath(archive),
+				Message: err.Error(),
+			})
+			continue
+		}
+
+		asset := storedAsset{
+			path:    archive,
+			name:    helmChart.Name(),
+			version: chartVersion,
+			digest:  digest,
+		}
+		if vendorDir := filepath.Dir(archive); vendorDir != filepath.Clean(manager.assetsPath) {
+			asset.vendor = filepath.Base(vendorDir)
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, issues, nil
+}
+
+// Returns the path of a file in the assets directory as it appears in chart URLs
+func (manager *Manager) relativePath(assetPath string) string {
+	relativePath, err := filepath.Rel(manager.assetsPath, assetPath)
+	if err != nil {
+		return assetPath
+	}
+
+	return path.Join(manager.baseURL, filepath.ToSlash(relativePath))
+}
+
+// Cross-validates the index, the chart assets, and the chart directories in
+// chartsPath. Index entries must match an asset and its digest, every asset
+// must be indexed, and every chart directory must match the latest asset of
+// the chart. Index shards must match the index, if enabled. Issues are
+// returned in sorted order
+func (manager *Manager) Check(chartsPath string) ([]Issue, error) {
+	assets, issues, err := manager.listAssets()
+	if err != nil {
+		return nil, err
+	}
+
+	assetsByURL := make(map[string]storedAsset)
+	for _, asset := range assets {
+		assetsByURL[manager.relativePath(asset.path)] = asset
+	}
+
+	indexed := make(map[string]bool)
+	for chartName, chartVersions := range manager.IndexFile.Entries {
+		for _, chartVersion := range chartVersions {
+			issue := Issue{
+				Chart:   chartName,
+				Version: chartVersion.Version,
+			}
+			if len(chartVersion.URLs) == 0 {
+				issue.Kind = IssueMissingAsset
+				issue.Path = chartName
+				issue.Message = fmt.Sprintf("index entry for version %s has no URL", chartVersion.Version)
+				issues = append(issues, issue)
+				continue
+			}
+			issue.Path = chartVersion.URLs[0]
+
+			asset, ok := assetsByURL[chartVersion.URLs[0]]
+			if !ok {
+				issue.Kind = IssueMissingAsset
+				issue.Message = fmt.Sprintf("index entry for %s (%s) has no asset", chartName, chartVersion.Version)
+				issues = append(issues, issue)
+				continue
+			}
+			indexed[chartVersion.URLs[0]] = true
+			issue.Asset = asset.path
+
+			if asset.name != chartName || asset.version.Original() != chartVersion.Version {
+				issue.Kind = IssueDigestMismatch
+				issue.Message = fmt.Sprintf("index entry for %s (%s) points to %s (%s)",
+					chartName, chartVersion.Version, asset.name, asset.version.Original())
+				issues = append(issues, issue)
+			} else if asset.digest != chartVersion.Digest {
+				issue.Kind = IssueDigestMismatch
+				issue.Message = fmt.Sprintf("index digest %s does not match asset digest %s", chartVersion.Digest, asset.digest)
+				issues = append(issues, issue)
+			}
+		}
+	}
+
+	latestAssets := make(map[string]storedAsset)
+	for _, asset := range assets {
+		url := manager.relativePath(asset.path)
+		if !indexed[url] {
+			issues = append(issues, Issue{
+				Kind:    IssueUnindexedAsset,
+				Path:    url,
+				Message: fmt.Sprintf("%s (%s) is not in the index", asset.name, asset.version.Original()),
+				Asset:   asset.path,
+			})
+		}
+		if asset.vendor == "" {
+			continue
+		}
+		chartPath := filepath.Join(chartsPath, asset.vendor, asset.name)
+		if latest, ok := latestAssets[chartPath]; !ok || asset.version.GreaterThan(latest.version) {
+			latestAssets[chartPath] = asset
+		}
+	}
+
+	chartIssues, err := checkChartDirectories(chartsPath, latestAssets)
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, chartIssues...)
+
+	if manager.ShardsPath != "" {
+		shardIssues, err := manager.checkShards()
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, shardIssues...)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Path != issues[j].Path {
+			return issues[i].Path < issues[j].Path
+		}
+		return issues[i].Kind < issues[j].Kind
+	})
+
+	return issues, nil
+}
+
+// Compares each chart directory with the latest asset of the chart
+func checkChartDirectories(chartsPath string, latestAssets map[string]storedAsset) ([]Issue, error) {
+	issues := make([]Issue, 0)
+	relativeChartPath := func(chartPath string) string {
+		relativePath, err := filepath.Rel(filepath.Dir(chartsPath), chartPath)
+		if err != nil {
+			return chartPath
+		}
+		return filepath.ToSlash(relativePath)
+	}
+
+	chartDirs, err := filepath.Glob(filepath.Join(chartsPath, "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	for _, chartDir := range chartDirs {
+		if info, err := os.Stat(chartDir); err != nil || !info.IsDir() {
+			continue
+		}
+		if _, ok := latestAssets[chartDir]; !ok {
+			issues = append(issues, Issue{
+				Kind:    IssueOrphanedChart,
+				Path:    relativeChartPath(chartDir),
+				Message: "chart directory has no assets",
+			})
+		}
+	}
+
+	tempDir, err := os.MkdirTemp("", "chartCheck")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	for chartPath, asset := range latestAssets {
+		issue := Issue{
+			Path:  relativeChartPath(chartPath),
+			Asset: asset.path,
+		}
+		if _, err := os.Stat(chartPath); os.IsNotExist(err) {
+			issue.Kind = IssueMissingChart
+			issue.Message = fmt.Sprintf("chart directory missing for %s (%s)", asset.name, asset.version.Original())
+			issues = append(issues, issue)
+			continue
+		}
+
+		extractPath := filepath.Join(tempDir, asset.vendor, asset.name)
+		err = conform.Gunzip(asset.path, extractPath)
+		if err != nil {
+			return nil, err
+		}
+
+		comparison, err := validate.CompareDirectories(extractPath, chartPath, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !comparison.Match {
+			changed := append(append(comparison.Modified, comparison.Added...), comparison.Removed...)
+			for i := range changed {
+				changed[i] = strings.TrimPrefix(changed[i], "/")
+			}
+			sort.Strings(changed)
+			issue.Kind = IssueStaleChart
+			issue.Message = fmt.Sprintf("chart directory does not match %s (%s): %s",
+				asset.name, asset.version.Original(), strings.Join(changed, ", "))
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues, nil
+}
+
+// Rebuilds index entries and chart directories from assets to resolve the
+// given issues. Orphaned chart directories are only removed if removeOrphans
+// is set, as they may hold charts whose assets were never committed. The
+// index is not written. Index shards are regenerated from the index when it
+// is written
+func (manager *Manager) Fix(chartsPath string, issues []Issue, removeOrphans bool) error {
+	for _, issue := range issues {
+		switch issue.Kind {
+		case IssueMissingAsset:
+			logrus.Infof("Removing %s (%s) from index\n", issue.Chart, issue.Version)
+			if err := manager.Remove(issue.Chart, issue.Version); err != nil {
+				return err
+			}
+		case IssueDigestMismatch, IssueUnindexedAsset:
+			if issue.Chart != "" {
+				manager.remove(issue.Chart, issue.Version)
+			}
+			logrus.Infof("Indexing %s\n", issue.Path)
+			if err := manager.Add(issue.Asset); err != nil {
+				return err
+			}
+		case IssueMissingChart, IssueStaleChart:
+			chartPath := filepath.Join(filepath.Dir(chartsPath), filepath.FromSlash(issue.Path))
+			logrus.Infof("Extracting %s to %s\n", manager.relativePath(issue.Asset), issue.Path)
+			if err := os.RemoveAll(chartPath); err != nil {
+				return err
+			}
+			if err := conform.Gunzip(issue.Asset, chartPath); err != nil {
+				return err
+			}
+		case IssueOrphanedChart:
+			if !removeOrphans {
+				logrus.Warnf("Keeping orphaned chart directory %s\n", issue.Path)
+				continue
+			}
+			chartPath := filepath.Join(filepath.Dir(chartsPath), filepath.FromSlash(issue.Path))
+			logrus.Infof("Removing %s\n", issue.Path)
+			if err := os.RemoveAll(chartPath); err != nil {
+				return err
+			}
+		case IssueMissingShard, IssueStaleShard, IssueOrphanedShard:
+			logrus.Infof("Regenerating %s\n", issue.Path)
+		default:
+			logrus.Warnf("Unable to fix %s\n", issue)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Returns the issues of the given kind
+func issuesOfKind(issues []Issue, kind string) []Issue {
+	matched := make([]Issue, 0)
+	for _, issue := range issues {
+		if issue.Kind == kind {
+			matched = append(matched, issue)
+		}
+	}
+
+	return matched
+}
+
+func TestFixOrphanedChart(t *testing.T) {
+	for _, removeOrphans := range []bool{false, true} {
+		repoRoot := t.TempDir()
+		chartsPath := filepath.Join(repoRoot, "charts")
+		orphanPath := filepath.Join(chartsPath, "acme", "orphan")
+		if err := os.MkdirAll(orphanPath, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(orphanPath, "Chart.yaml"), []byte("name: orphan\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		assetPath := saveTestAsset(t, repoRoot, "acme", "foo", "1.0.0", nil)
+
+		manager, err := Load(filepath.Join(repoRoot, "index.yaml"), filepath.Join(repoRoot, "assets"), "assets")
+		if err != nil {
+			t.Fatal(err)
+		}
+		issues, err := manager.Check(chartsPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if orphaned := issuesOfKind(issues, IssueOrphanedChart); len(orphaned) != 1 || orphaned[0].Path != "charts/acme/orphan" {
+			t.Fatalf("expected charts/acme/orphan to be orphaned, got %v", issues)
+		}
+
+		if err = manager.Fix(chartsPath, issues, removeOrphans); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = os.Stat(orphanPath)
+		if removeOrphans && !os.IsNotExist(err) {
+			t.Errorf("expected orphaned chart directory to be removed, got %v", err)
+		}
+		if !removeOrphans && err != nil {
+			t.Errorf("expected orphaned chart directory to be kept, got %v", err)
+		}
+		if _, err = os.Stat(filepath.Join(chartsPath, "acme", "foo", "Chart.yaml")); err != nil {
+			t.Errorf("expected chart directory to be extracted from %s: %v", assetPath, err)
+		}
+		if _, ok := manager.IndexFile.Entries["foo"]; !ok {
+			t.Error("expected unindexed asset to be indexed")
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"helm.sh/helm/v3/pkg/repo"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	//DiffText formats an index diff as plain text
+	DiffText = "text"
+	//DiffMarkdown formats an index diff as Markdown
+	DiffMarkdown = "markdown"
+	//DiffJSON formats an index diff as JSON
+	DiffJSON = "json"
+)
+
+// FieldChange is a changed metadata field or annotation of a chart version
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// VersionChange lists the changed fields of a chart version
+type VersionChange struct {
+	Version string        `json:"version"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// ChartDiff lists the changes to the versions of a chart
+type ChartDiff struct {
+	Chart string `json:"chart"`
+	//Added is true if the chart is not in the old index
+	Added bool `json:"added,omitempty"`
+	//Removed is true if the chart is not in the new index
+	Removed         bool            `json:"removed,omitempty"`
+	AddedVersions   []string        `json:"addedVersions,omitempty"`
+	RemovedVersions []string        `json:"removedVersions,omitempty"`
+	ChangedVersions []VersionChange `json:"changedVersions,omitempty"`
+}
+
+// IndexDiff is a semantic diff between two repository indexes
+type IndexDiff struct {
+	Charts []ChartDiff `json:"charts"`
+}
+
+// Reads a repository index from a file at a git revision. Returns an empty
+// index if the file does not exist at that revision
+func ReadRevision(repoPath, revision, indexPath string) (*repo.IndexFile, error) {
+	r, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := r.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %s: %w", revision, err)
+	}
+
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := commit.File(indexPath)
+	if err == object.ErrFileNotFound {
+		return repo.NewIndexFile(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	indexFile := repo.NewIndexFile()
+	err = yaml.Unmarshal([]byte(contents), indexFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s at %s: %w", indexPath, revision, err)
+	}
+	indexFile.SortEntries()
+
+	return indexFile, nil
+}
+
+// Returns the versions of a chart keyed by version
+func versionMap(chartVersions repo.ChartVersions) map[string]*repo.ChartVersion {
+	versions := make(map[string]*repo.ChartVersion)
+	for _, chartVersion := range chartVersions {
+		if chartVersion != nil && chartVersion.Metadata != nil {
+			versions[chartVersion.Version] = chartVersion
+		}
+	}
+
+	return versions
+}
+
+// Sorts versions newest first. Versions that are not valid semver sort last
+func sortVersions(versions []string) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		left, leftErr := semver.NewVersion(versions[i])
+		right, rightErr := semver.NewVersion(versions[j])
+		if leftErr != nil || rightErr != nil {
+			if leftErr == nil || rightErr == nil {
+				return leftErr == nil
+			}
+			return versions[i] > versions[j]
+		}
+		return left.GreaterThan(right)
+	})
+}
+
+// Compares the metadata and annotations of two entries of a chart version
+func compareVersions(oldVersion, newVersion *repo.ChartVersion) []FieldChange {
+	changes := make([]FieldChange, 0)
+	compare := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	compare("appVersion", oldVersion.AppVersion, newVersion.AppVersion)
+	compare("kubeVersion", oldVersion.KubeVersion, newVersion.KubeVersion)
+	compare("deprecated", strconv.FormatBool(oldVersion.Deprecated), strconv.FormatBool(newVersion.Deprecated))
+	compare("description", oldVersion.Description, newVersion.Description)
+	compare("icon", oldVersion.Icon, newVersion.Icon)
+	compare("type", oldVersion.Type, newVersion.Type)
+	compare("digest", oldVersion.Digest, newVersion.Digest)
+	compare("urls", strings.Join(oldVersion.URLs, ","), strings.Join(newVersion.URLs, ","))
+
+	annotations := make([]string, 0)
+	for annotation := range oldVersion.Annotations {
+		annotations = append(annotations, annotation)
+	}
+	for annotation := range newVersion.Annotations {
+		if _, ok := oldVersion.Annotations[annotation]; !ok {
+			annotations = append(annotations, annotation)
+		}
+	}
+	sort.Strings(annotations)
+	for _, annotation := range annotations {
+		compare(fmt.Sprintf("annotations[%s]", annotation), oldVersion.Annotations[annotation], newVersion.Annotations[annotation])
+	}
+
+	return changes
+}
+
+// Compares two repository indexes by chart and version. Created and
+// generated times are ignored
+func Diff(oldIndex, newIndex *repo.IndexFile) IndexDiff {
+	chartNames := make([]string, 0)
+	for chartName := range oldIndex.Entries {
+		chartNames = append(chartNames, chartName)
+	}
+	for chartName := range newIndex.Entries {
+		if _, ok := oldIndex.Entries[chartName]; !ok {
+			chartNames = append(chartNames, chartName)
+		}
+	}
+	sort.Strings(chartNames)
+
+	indexDiff := IndexDiff{Charts: make([]ChartDiff, 0)}
+	for _, chartName := range chartNames {
+		oldVersions := versionMap(oldIndex.Entries[chartName])
+		newVersions := versionMap(newIndex.Entries[chartName])
+		chartDiff := ChartDiff{
+			Chart:   chartName,
+			Added:   len(oldVersions) == 0,
+			Removed: len(newVersions) == 0,
+		}
+
+		for version, newVersion := range newVersions {
+			oldVersion, ok := oldVersions[version]
+			if !ok {
+				chartDiff.AddedVersions = append(chartDiff.AddedVersions, version)
+				continue
+			}
+			if changes := compareVersions(oldVersion, newVersion); len(changes) > 0 {
+				chartDiff.ChangedVersions = append(chartDiff.ChangedVersions, VersionChange{
+					Version: version,
+					Changes: changes,
+				})
+			}
+		}
+		for version := range oldVersions {
+			if _, ok := newVersions[version]; !ok {
+				chartDiff.RemovedVersions = append(chartDiff.RemovedVersions, version)
+			}
+		}
+
+		if len(chartDiff.AddedVersions)+len(chartDiff.RemovedVersions)+len(chartDiff.ChangedVersions) == 0 {
+			continue
+		}
+
+		sortVersions(chartDiff.AddedVersions)
+		sortVersions(chartDiff.RemovedVersions)
+		changedVersions := make([]string, 0, len(chartDiff.ChangedVersions))
+		changesByVersion := make(map[string]VersionChange)
+		for _, versionChange := range chartDiff.ChangedVersions {
+			changedVersions = append(changedVersions, versionChange.Version)
+			changesByVersion[versionChange.Version] = versionChange
+		}
+		sortVersions(changedVersions)
+		for i, version := range changedVersions {
+			chartDiff.ChangedVersions[i] = changesByVersion[version]
+		}
+
+		indexDiff.Charts = append(indexDiff.Charts, chartDiff)
+	}
+
+	return indexDiff
+}
+
+// Returns a field value for display
+func displayValue(value string) string {
+	if value == "" {
+		return "(unset)"
+	}
+
+	return value
+}
+
+// Returns the chart heading with its added or removed state
+func (chartDiff ChartDiff) heading() string {
+	switch {
+	case chartDiff.Added:
+		return chartDiff.Chart + " (added)"
+	case chartDiff.Removed:
+		return chartDiff.Chart + " (removed)"
+	}
+
+	return chartDiff.Chart
+}
+
+// Formats the diff as plain text
+func (indexDiff IndexDiff) Text() string {
+	if len(indexDiff.Charts) == 0 {
+		return "No changes\n"
+	}
+
+	var builder strings.Builder
+	for _, chartDiff := range indexDiff.Charts {
+		fmt.Fprintf(&builder, "%s\n", chartDiff.heading())
+		for _, version := range chartDiff.AddedVersions {
+			fmt.Fprintf(&builder, "  + %s\n", version)
+		}
+		for _, version := range chartDiff.RemovedVersions {
+			fmt.Fprintf(&builder, "  - %s\n", version)
+		}
+		for _, versionChange := range chartDiff.ChangedVersions {
+			fmt.Fprintf(&builder, "  ~ %s\n", versionChange.Version)
+			for _, change := range versionChange.Changes {
+				fmt.Fprintf(&builder, "      %s: %s -> %s\n", change.Field, displayValue(change.Old), displayValue(change.New))
+			}
+		}
+	}
+
+	return builder.String()
+}
+
+// Formats the diff as Markdown
+func (indexDiff IndexDiff) Markdown() string {
+	if len(indexDiff.Charts) == 0 {
+		return "No changes\n"
+	}
+
+	var builder strings.Builder
+	for i, chartDiff := range indexDiff.Charts {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		fmt.Fprintf(&builder, "### %s\n", chartDiff.heading())
+		for _, version := range chartDiff.AddedVersions {
+			fmt.Fprintf(&builder, "- Added `%s`\n", version)
+		}
+		for _, version := range chartDiff.RemovedVersions {
+			fmt.Fprintf(&builder, "- Removed `%s`\n", version)
+		}
+		for _, versionChange := range chartDiff.ChangedVersions {
+			fmt.Fprintf(&builder, "- Changed `%s`\n", versionChange.Version)
+			for _, change := range versionChange.Changes {
+				fmt.Fprintf(&builder, "  - `%s`: `%s` -> `%s`\n", change.Field, displayValue(change.Old), displayValue(change.New))
+			}
+		}
+	}
+
+	return builder.String()
+}
+
+// Formats the diff as indented JSON
+func (indexDiff IndexDiff) JSON() (string, error) {
+	out, err := json.MarshalIndent(indexDiff, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(out) + "\n", nil
+}
+
+// Formats the diff as DiffText, DiffMarkdown, or DiffJSON
+func (indexDiff IndexDiff) Format(format string) (string, error) {
+	switch format {
+	case DiffText, "":
+		return indexDiff.Text(), nil
+	case DiffMarkdown:
+		return indexDiff.Markdown(), nil
+	case DiffJSON:
+		return indexDiff.JSON()
+	}
+
+	return "", fmt.Errorf("unknown output format '%s'. Expected one of %s, %s, %s", format, DiffText, DiffMarkdown, DiffJSON)
+}
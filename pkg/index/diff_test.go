@@ -0,0 +1,255 @@
+package index
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Returns an index with the given chart versions, keyed by chart name
+func testDiffIndex(entries map[string][]*repo.ChartVersion) *repo.IndexFile {
+	indexFile := repo.NewIndexFile()
+	for chartName, chartVersions := range entries {
+		indexFile.Entries[chartName] = chartVersions
+	}
+
+	return indexFile
+}
+
+// Returns an index entry for a chart version
+func testDiffVersion(name, version string, annotations map[string]string) *repo.ChartVersion {
+	return &repo.ChartVersion{
+		Metadata: &chart.Metadata{
+			Name:        name,
+			Version:     version,
+			AppVersion:  "1.0.0",
+			Annotations: annotations,
+		},
+		URLs:    []string{"assets/acme/" + name + "-" + version + ".tgz"},
+		Digest:  "sha256:" + version,
+		Created: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// Returns a diff with a chart of each kind of change
+func testIndexDiff() IndexDiff {
+	oldIndex := testDiffIndex(map[string][]*repo.ChartVersion{
+		"foo": {
+			testDiffVersion("foo", "1.0.0", map[string]string{"catalog.cattle.io/featured": "1"}),
+			testDiffVersion("foo", "1.1.0", nil),
+		},
+		"removed": {testDiffVersion("removed", "0.1.0", nil)},
+	})
+	changed := testDiffVersion("foo", "1.0.0", map[string]string{"catalog.cattle.io/hidden": "true"})
+	changed.AppVersion = "1.0.1"
+	changed.Created = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newIndex := testDiffIndex(map[string][]*repo.ChartVersion{
+		"foo": {changed, testDiffVersion("foo", "2.0.0", nil)},
+		"added": {
+			testDiffVersion("added", "0.1.0", nil),
+			testDiffVersion("added", "0.2.0", nil),
+		},
+	})
+
+	return Diff(oldIndex, newIndex)
+}
+
+func TestDiff(t *testing.T) {
+	expected := IndexDiff{Charts: []ChartDiff{
+		{Chart: "added", Added: true, AddedVersions: []string{"0.2.0", "0.1.0"}},
+		{
+			Chart:           "foo",
+			AddedVersions:   []string{"2.0.0"},
+			RemovedVersions: []string{"1.1.0"},
+			ChangedVersions: []VersionChange{{
+				Version: "1.0.0",
+				Changes: []FieldChange{
+					{Field: "appVersion", Old: "1.0.0", New: "1.0.1"},
+					{Field: "annotations[catalog.cattle.io/featured]", Old: "1"},
+					{Field: "annotations[catalog.cattle.io/hidden]", New: "true"},
+				},
+			}},
+		},
+		{Chart: "removed", Removed: true, RemovedVersions: []string{"0.1.0"}},
+	}}
+
+	if indexDiff := testIndexDiff(); !reflect.DeepEqual(indexDiff, expected) {
+		t.Errorf("expected %+v, got %+v", expected, indexDiff)
+	}
+}
+
+func TestDiffUnchanged(t *testing.T) {
+	oldIndex := testDiffIndex(map[string][]*repo.ChartVersion{"foo": {testDiffVersion("foo", "1.0.0", nil)}})
+	newVersion := testDiffVersion("foo", "1.0.0", nil)
+	newVersion.Created = time.Now()
+	newIndex := testDiffIndex(map[string][]*repo.ChartVersion{"foo": {newVersion}})
+	newIndex.Generated = time.Now()
+
+	indexDiff := Diff(oldIndex, newIndex)
+	if len(indexDiff.Charts) != 0 {
+		t.Errorf("expected created and generated times to be ignored, got %+v", indexDiff.Charts)
+	}
+	if text := indexDiff.Text(); text != "No changes\n" {
+		t.Errorf("expected no changes, got %q", text)
+	}
+}
+
+func TestSortVersions(t *testing.T) {
+	versions := []string{"1.0.0", "invalid", "2.0.0-rc.1", "v1.10.0", "also-invalid", "2.0.0", "1.9.0"}
+	expected := []string{"2.0.0", "2.0.0-rc.1", "v1.10.0", "1.9.0", "1.0.0", "invalid", "also-invalid"}
+	sortVersions(versions)
+	if !reflect.DeepEqual(versions, expected) {
+		t.Errorf("expected %v, got %v", expected, versions)
+	}
+}
+
+func TestIndexDiffFormat(t *testing.T) {
+	indexDiff := testIndexDiff()
+
+	text := `added (added)
+  + 0.2.0
+  + 0.1.0
+foo
+  + 2.0.0
+  - 1.1.0
+  ~ 1.0.0
+      appVersion: 1.0.0 -> 1.0.1
+      annotations[catalog.cattle.io/featured]: 1 -> (unset)
+      annotations[catalog.cattle.io/hidden]: (unset) -> true
+removed (removed)
+  - 0.1.0
+`
+	markdown := "### added (added)\n" +
+		"- Added `0.2.0`\n" +
+		"- Added `0.1.0`\n" +
+		"\n" +
+		"### foo\n" +
+		"- Added `2.0.0`\n" +
+		"- Removed `1.1.0`\n" +
+		"- Changed `1.0.0`\n" +
+		"  - `appVersion`: `1.0.0` -> `1.0.1`\n" +
+		"  - `annotations[catalog.cattle.io/featured]`: `1` -> `(unset)`\n" +
+		"  - `annotations[catalog.cattle.io/hidden]`: `(unset)` -> `true`\n" +
+		"\n" +
+		"### removed (removed)\n" +
+		"- Removed `0.1.0`\n"
+
+	for format, expected := range map[string]string{"": text, DiffText: text, DiffMarkdown: markdown} {
+		out, err := indexDiff.Format(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", format, expected, out)
+		}
+	}
+
+	out, err := indexDiff.Format(DiffJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded IndexDiff
+	if err = json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, indexDiff) {
+		t.Errorf("expected JSON to decode to %+v, got %+v", indexDiff, decoded)
+	}
+	if strings.Contains(out, `"removed": false`) || !strings.Contains(out, `"addedVersions": [`) {
+		t.Errorf("unexpected JSON fields in %s", out)
+	}
+
+	if _, err = indexDiff.Format("yaml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if out := (IndexDiff{}).Markdown(); out != "No changes\n" {
+		t.Errorf("expected no changes, got %q", out)
+	}
+}
+
+// Creates a repository with a commit without an index, followed by a commit
+// adding the given index as index.yaml. Returns the repository path
+func initIndexGitRepo(t *testing.T, indexFile *repo.IndexFile) string {
+	t.Helper()
+	repoPath := t.TempDir()
+	r, err := git.PlainInit(repoPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitOptions := &git.CommitOptions{Author: &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}}
+
+	if err = os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wt.Add("README.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wt.Commit("Add README", commitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := yaml.Marshal(indexFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(repoPath, "index.yaml"), out, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wt.Add("index.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wt.Commit("Add index", commitOptions); err != nil {
+		t.Fatal(err)
+	}
+
+	return repoPath
+}
+
+func TestReadRevision(t *testing.T) {
+	indexFile := testDiffIndex(map[string][]*repo.ChartVersion{
+		"foo": {testDiffVersion("foo", "1.0.0", nil), testDiffVersion("foo", "2.0.0", nil)},
+	})
+	repoPath := initIndexGitRepo(t, indexFile)
+
+	current, err := ReadRevision(repoPath, "HEAD", "index.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if versions := current.Entries["foo"]; len(versions) != 2 || versions[0].Version != "2.0.0" {
+		t.Errorf("expected sorted versions of foo, got %+v", versions)
+	}
+	if indexDiff := Diff(indexFile, current); len(indexDiff.Charts) != 0 {
+		t.Errorf("expected index at HEAD to match committed index, got %+v", indexDiff.Charts)
+	}
+
+	previous, err := ReadRevision(repoPath, "HEAD~1", "index.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previous.Entries) != 0 {
+		t.Errorf("expected empty index before index.yaml was committed, got %+v", previous.Entries)
+	}
+
+	if _, err = ReadRevision(repoPath, "HEAD~5", "index.yaml"); err == nil {
+		t.Error("expected error for unknown revision")
+	}
+	if _, err = ReadRevision(t.TempDir(), "HEAD", "index.yaml"); err == nil {
+		t.Error("expected error outside a git repository")
+	}
+}
@@ -0,0 +1,208 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/samuelattwood/partner-charts-ci/pkg/sign"
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// Manager holds a repository index in memory so that it is read once, updated
+// for the chart assets written during a run, and written once at the end
+type Manager struct {
+	//IndexFile is the in-memory repository index
+	IndexFile *repo.IndexFile
+	//ShardsPath is the directory per-vendor index shards are written to. Shards are not written if empty
+	ShardsPath string
+	//Signer signs the index and asset checksums when the index is written. Nothing is signed if nil
+	Signer sign.Signer
+	//indexPath is the path of the index yaml on disk
+	indexPath string
+	//assetsPath is the directory chart assets are stored in
+	assetsPath string
+	//baseURL is prepended to asset paths relative to assetsPath to form chart URLs
+	baseURL  string
+	modified bool
+}
+
+// Loads the index at indexPath, or an empty index if it does not exist.
+// Assets added to the index must be stored under assetsPath and are given
+// URLs relative to baseURL
+func Load(indexPath, assetsPath, baseURL string) (*Manager, error) {
+	manager := &Manager{
+		indexPath:  indexPath,
+		assetsPath: assetsPath,
+		baseURL:    baseURL,
+	}
+
+	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+		logrus.Debugf("%s not found. Creating new index\n", indexPath)
+		manager.IndexFile = repo.NewIndexFile()
+		manager.modified = true
+		return manager, nil
+	}
+
+	indexFile, err := repo.LoadIndexFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	manager.IndexFile = indexFile
+
+	return manager, nil
+}
+
+// Returns true if the index has changed since it was loaded or written
+func (manager *Manager) Modified() bool {
+	return manager.modified
+}
+
+// Adds a chart asset to the index, replacing any existing entry for the same
+// chart version. The entry is generated as by a full rebuild with
+// repo.IndexDirectory. An existing entry with the same digest and URL is kept
+// unchanged, including its created time
+func (manager *Manager) Add(assetPath string) error {
+	relativePath, err := filepath.Rel(manager.assetsPath, assetPath)
+	if err != nil {
+		return err
+	}
+	parentDir, fileName := filepath.Split(relativePath)
+	parentURL := path.Join(manager.baseURL, filepath.ToSlash(parentDir))
+
+	helmChart, err := loader.Load(assetPath)
+	if err != nil {
+		return err
+	}
+
+	digest, err := provenance.DigestFile(assetPath)
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range manager.IndexFile.Entries[helmChart.Name()] {
+		if existing.Version != helmChart.Metadata.Version {
+			continue
+		}
+		if existing.Digest == digest && len(existing.URLs) == 1 && existing.URLs[0] == path.Join(parentURL, fileName) {
+			logrus.Debugf("%s (%s) is unchanged in index\n", helmChart.Name(), helmChart.Metadata.Version)
+			return nil
+		}
+	}
+
+	manager.remove(helmChart.Name(), helmChart.Metadata.Version)
+	err = manager.IndexFile.MustAdd(helmChart.Metadata, fileName, parentURL, digest)
+	if err != nil {
+		return fmt.Errorf("failed adding %s to index: %w", relativePath, err)
+	}
+	manager.modified = true
+
+	logrus.Debugf("Indexed %s (%s)\n", helmChart.Name(), helmChart.Metadata.Version)
+
+	return nil
+}
+
+// Re-indexes every chart asset in the assets directory, as a full rebuild of
+// the index with repo.IndexDirectory would. Entries of unchanged assets are
+// kept, including their created time. Entries without an asset are left for
+// Check to report. The index is not written
+func (manager *Manager) Rebuild() error {
+	assets, _, err := manager.listAssets()
+	if err != nil {
+		return err
+	}
+	for _, asset := range assets {
+		if err = manager.Add(asset.path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the vendor directory of an index entry's asset stored under
+// baseURL, if any
+func AssetVendor(chartVersion *repo.ChartVersion, baseURL string) string {
+	if len(chartVersion.URLs) == 0 {
+		return ""
+	}
+	assetPath := strings.Split(strings.TrimPrefix(chartVersion.URLs[0], baseURL+"/"), "/")
+	if len(assetPath) != 2 || assetPath[0] == "" {
+		return ""
+	}
+
+	return assetPath[0]
+}
+
+// Removes a chart version from the index
+func (manager *Manager) Remove(chartName, version string) error {
+	if _, ok := manager.IndexFile.Entries[chartName]; !ok {
+		return fmt.Errorf("%s not present in index entries", chartName)
+	}
+	if !manager.remove(chartName, version) {
+		return fmt.Errorf("version %s not found for chart %s in index", version, chartName)
+	}
+	manager.modified = true
+
+	return nil
+}
+
+// Removes a chart version from the index entries. Charts without remaining
+// versions are removed. Returns true if the version was found
+func (manager *Manager) remove(chartName, version string) bool {
+	indexEntries := manager.IndexFile.Entries[chartName]
+	for i, entryVersion := range indexEntries {
+		if entryVersion.Version != version {
+			continue
+		}
+		entries := make(repo.ChartVersions, 0, len(indexEntries)-1)
+		entries = append(entries, indexEntries[:i]...)
+		entries = append(entries, indexEntries[i+1:]...)
+		if len(entries) == 0 {
+			delete(manager.IndexFile.Entries, chartName)
+		} else {
+			manager.IndexFile.Entries[chartName] = entries
+		}
+		return true
+	}
+
+	return false
+}
+
+// Sorts the index entries and atomically writes the index to disk. The index
+// is only written, and its generated time updated, if it has been modified.
+// Index shards are then brought in line with the index, and the index and
+// asset checksums signed, if enabled
+func (manager *Manager) Write() error {
+	if !manager.modified {
+		logrus.Debugf("%s is unchanged\n", manager.indexPath)
+	} else {
+		manager.IndexFile.Generated = time.Now()
+		manager.IndexFile.SortEntries()
+		err := manager.IndexFile.WriteFile(manager.indexPath, 0644)
+		if err != nil {
+			return err
+		}
+		manager.modified = false
+	}
+
+	if manager.ShardsPath != "" {
+		err := manager.writeShards()
+		if err != nil {
+			return err
+		}
+	}
+
+	if manager.Signer == nil {
+		return nil
+	}
+
+	return manager.writeSignatures()
+}
@@ -0,0 +1,177 @@
+package index
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// Saves a minimal chart archive to assets/<vendor> under repoRoot and returns
+// its path
+func saveTestAsset(t *testing.T, repoRoot, vendor, name, version string, annotations map[string]string) string {
+	t.Helper()
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:  chart.APIVersionV2,
+			Name:        name,
+			Version:     version,
+			AppVersion:  version,
+			Annotations: annotations,
+		},
+		Templates: []*chart.File{
+			{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\n")},
+		},
+	}
+
+	assetDir := filepath.Join(repoRoot, "assets", vendor)
+	if err := os.MkdirAll(assetDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	assetPath, err := chartutil.Save(helmChart, assetDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return assetPath
+}
+
+func TestWriteUnchangedIndex(t *testing.T) {
+	repoRoot := t.TempDir()
+	indexPath := filepath.Join(repoRoot, "index.yaml")
+	assetsPath := filepath.Join(repoRoot, "assets")
+	assetPath := saveTestAsset(t, repoRoot, "acme", "foo", "1.0.0", nil)
+
+	manager, err := Load(indexPath, assetsPath, "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = manager.Add(assetPath); err != nil {
+		t.Fatal(err)
+	}
+	if err = manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err = os.Chtimes(indexPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err = Load(indexPath, assetsPath, "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = manager.Add(assetPath); err != nil {
+		t.Fatal(err)
+	}
+	if manager.Modified() {
+		t.Error("index modified by adding an unchanged asset")
+	}
+	if err = manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	rewritten, err := os.ReadFile(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, rewritten) {
+		t.Errorf("index changed by no-op write:\n%s\n---\n%s", written, rewritten)
+	}
+	info, err := os.Stat(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Errorf("index rewritten by no-op write, modified at %s", info.ModTime())
+	}
+}
+
+func TestAddChangedAsset(t *testing.T) {
+	repoRoot := t.TempDir()
+	indexPath := filepath.Join(repoRoot, "index.yaml")
+	assetsPath := filepath.Join(repoRoot, "assets")
+	assetPath := saveTestAsset(t, repoRoot, "acme", "foo", "1.0.0", nil)
+
+	manager, err := Load(indexPath, assetsPath, "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = manager.Add(assetPath); err != nil {
+		t.Fatal(err)
+	}
+	if err = manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if manager.Modified() {
+		t.Error("index modified after write")
+	}
+
+	saveTestAsset(t, repoRoot, "acme", "foo", "1.0.0", map[string]string{"catalog.cattle.io/hidden": "true"})
+	if err = manager.Add(assetPath); err != nil {
+		t.Fatal(err)
+	}
+	if !manager.Modified() {
+		t.Error("index not modified by adding a changed asset")
+	}
+	chartVersions := manager.IndexFile.Entries["foo"]
+	if len(chartVersions) != 1 || chartVersions[0].URLs[0] != "assets/acme/foo-1.0.0.tgz" || chartVersions[0].Annotations["catalog.cattle.io/hidden"] != "true" {
+		t.Errorf("unexpected index entries %+v", chartVersions)
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	repoRoot := t.TempDir()
+	indexPath := filepath.Join(repoRoot, "index.yaml")
+	assetsPath := filepath.Join(repoRoot, "assets")
+	manager, err := Load(indexPath, assetsPath, "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		if err = manager.Add(saveTestAsset(t, repoRoot, "acme", "foo", version, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	saveTestAsset(t, repoRoot, "acme", "foo", "1.1.0", map[string]string{"catalog.cattle.io/hidden": "true"})
+	saveTestAsset(t, repoRoot, "other", "bar", "2.0.0", nil)
+
+	manager, err = Load(indexPath, assetsPath, "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unchanged, err := manager.IndexFile.Get("foo", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := unchanged.Created
+	if err = manager.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !manager.Modified() {
+		t.Error("expected rebuilt index to be modified")
+	}
+	if unchanged, err = manager.IndexFile.Get("foo", "1.0.0"); err != nil || !unchanged.Created.Equal(created) {
+		t.Errorf("expected unchanged entry to be kept, got %v, %v", unchanged, err)
+	}
+	if changed, err := manager.IndexFile.Get("foo", "1.1.0"); err != nil || changed.Annotations["catalog.cattle.io/hidden"] != "true" {
+		t.Errorf("expected changed asset to be re-indexed, got %v, %v", changed, err)
+	}
+	if added, err := manager.IndexFile.Get("bar", "2.0.0"); err != nil || added.URLs[0] != "assets/other/bar-2.0.0.tgz" {
+		t.Errorf("expected unindexed asset to be indexed, got %v, %v", added, err)
+	}
+}
@@ -0,0 +1,90 @@
+package index
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/samuelattwood/partner-charts-ci/pkg/parse"
+
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+const (
+	//RetentionDateFormat is the layout of the Retention Before date
+	RetentionDateFormat = "2006-01-02"
+
+	//untrackedGroup groups versions of Major.Minor versions that are not tracked
+	untrackedGroup = "untracked"
+)
+
+// Returns the chart versions not kept by the retention policy, newest first.
+// KeepPerMinor keeps the newest versions of each tracked Major.Minor version,
+// matched as TrackVersions in upstream.yaml. Versions of Major.Minor versions
+// that are not tracked share a single group. If no versions are tracked,
+// versions are grouped by their Major.Minor version. The latest version of the
+// chart and of each tracked Major.Minor version are always kept
+func PrunableVersions(chartVersions repo.ChartVersions, retention parse.Retention, tracked []string) (repo.ChartVersions, error) {
+	prunable := make(repo.ChartVersions, 0)
+	if retention.IsEmpty() || len(chartVersions) < 2 {
+		return prunable, nil
+	}
+	if retention.KeepPerMinor < 0 {
+		return nil, fmt.Errorf("invalid retention KeepPerMinor %d", retention.KeepPerMinor)
+	}
+
+	trackedMinors := make(map[string]bool)
+	for _, trackedVersion := range tracked {
+		semVer, err := semver.NewVersion(trackedVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tracked version '%s': %w", trackedVersion, err)
+		}
+		trackedMinors[fmt.Sprintf("%d.%d", semVer.Major(), semVer.Minor())] = true
+	}
+
+	var before time.Time
+	if retention.Before != "" {
+		var err error
+		before, err = time.Parse(RetentionDateFormat, retention.Before)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention date '%s': %w", retention.Before, err)
+		}
+	}
+
+	type sortedVersion struct {
+		chartVersion *repo.ChartVersion
+		semVer       *semver.Version
+	}
+	sorted := make([]sortedVersion, 0, len(chartVersions))
+	for _, chartVersion := range chartVersions {
+		semVer, err := semver.NewVersion(chartVersion.Version)
+		if err != nil {
+			return nil, err
+		}
+		sorted = append(sorted, sortedVersion{chartVersion, semVer})
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].semVer.GreaterThan(sorted[j].semVer)
+	})
+
+	keptPerMinor := make(map[string]int)
+	for i, version := range sorted {
+		minor := fmt.Sprintf("%d.%d", version.semVer.Major(), version.semVer.Minor())
+		if len(trackedMinors) > 0 && !trackedMinors[minor] {
+			minor = untrackedGroup
+		}
+		keptPerMinor[minor]++
+		if i == 0 || (trackedMinors[minor] && keptPerMinor[minor] == 1) {
+			continue
+		}
+
+		if retention.KeepPerMinor > 0 && keptPerMinor[minor] > retention.KeepPerMinor {
+			prunable = append(prunable, version.chartVersion)
+		} else if !before.IsZero() && version.chartVersion.Created.Before(before) {
+			prunable = append(prunable, version.chartVersion)
+		}
+	}
+
+	return prunable, nil
+}
@@ -0,0 +1,105 @@
+package index
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samuelattwood/partner-charts-ci/pkg/parse"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// Returns chart versions created on consecutive days in the given order
+func testChartVersions(versions ...string) repo.ChartVersions {
+	created := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	chartVersions := make(repo.ChartVersions, 0, len(versions))
+	for i, version := range versions {
+		chartVersions = append(chartVersions, &repo.ChartVersion{
+			Metadata: &chart.Metadata{Name: "foo", Version: version},
+			Created:  created.AddDate(0, 0, i),
+		})
+	}
+
+	return chartVersions
+}
+
+func TestPrunableVersions(t *testing.T) {
+	chartVersions := testChartVersions("1.0.0", "1.0.1", "1.0.2", "1.1.0", "1.1.1", "2.0.0", "2.0.1")
+
+	tests := []struct {
+		name      string
+		retention parse.Retention
+		tracked   []string
+		expected  []string
+		fails     bool
+	}{
+		{
+			name:      "no retention",
+			retention: parse.Retention{},
+			expected:  []string{},
+		},
+		{
+			name:      "grouped by minor without tracked versions",
+			retention: parse.Retention{KeepPerMinor: 1},
+			expected:  []string{"2.0.0", "1.1.0", "1.0.1", "1.0.0"},
+		},
+		{
+			name:      "grouped by tracked minor",
+			retention: parse.Retention{KeepPerMinor: 1},
+			tracked:   []string{"1.0", "2.0"},
+			expected:  []string{"2.0.0", "1.1.0", "1.0.1", "1.0.0"},
+		},
+		{
+			name:      "untracked minors share a group",
+			retention: parse.Retention{KeepPerMinor: 2},
+			tracked:   []string{"2.0"},
+			expected:  []string{"1.0.2", "1.0.1", "1.0.0"},
+		},
+		{
+			name:      "newest version of each tracked minor is kept",
+			retention: parse.Retention{Before: "2023-01-07"},
+			tracked:   []string{"1.0", "1.1"},
+			expected:  []string{"2.0.0", "1.1.0", "1.0.1", "1.0.0"},
+		},
+		{
+			name:      "before without tracked versions",
+			retention: parse.Retention{Before: "2023-01-04"},
+			expected:  []string{"1.0.2", "1.0.1", "1.0.0"},
+		},
+		{
+			name:      "invalid tracked version",
+			retention: parse.Retention{KeepPerMinor: 1},
+			tracked:   []string{"latest"},
+			fails:     true,
+		},
+		{
+			name:      "invalid date",
+			retention: parse.Retention{Before: "01/01/2023"},
+			fails:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prunable, err := PrunableVersions(chartVersions, test.retention, test.tracked)
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, got %v", prunable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			versions := make([]string, 0, len(prunable))
+			for _, chartVersion := range prunable {
+				versions = append(versions, chartVersion.Version)
+			}
+			if !reflect.DeepEqual(versions, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, versions)
+			}
+		})
+	}
+}
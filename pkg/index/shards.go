@@ -0,0 +1,256 @@
+package index
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/repo"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	//ShardsFile is the name of the index of shards in the shards directory
+	ShardsFile = "shards.yaml"
+	//shardFile is the name of each vendor index shard, so that each vendor
+	//directory can be added as a Helm repository
+	shardFile = "index.yaml"
+)
+
+// Shard describes the index of a single vendor
+type Shard struct {
+	//Path is the shard file relative to the repository index
+	Path string `json:"path"`
+	//Digest is the SHA-256 digest of the shard file
+	Digest string   `json:"digest"`
+	Charts []string `json:"charts"`
+}
+
+// ShardIndex lists the per-vendor index shards
+type ShardIndex struct {
+	APIVersion string           `json:"apiVersion"`
+	Generated  time.Time        `json:"generated"`
+	Shards     map[string]Shard `json:"shards"`
+}
+
+// Generates the per-vendor index shards and the index of shards from the
+// index. Chart URLs are rewritten relative to each shard. Returns the contents
+// of each file keyed by path
+func (manager *Manager) generateShards() (map[string][]byte, error) {
+	repoRoot := filepath.Dir(manager.indexPath)
+	shards := make(map[string]*repo.IndexFile)
+	for chartName, chartVersions := range manager.IndexFile.Entries {
+		for _, chartVersion := range chartVersions {
+			vendor := AssetVendor(chartVersion, manager.baseURL)
+			if vendor == "" {
+				logrus.Debugf("%s (%s) is not stored under a vendor. Not added to shards\n", chartName, chartVersion.Version)
+				continue
+			}
+			if _, ok := shards[vendor]; !ok {
+				shards[vendor] = &repo.IndexFile{
+					APIVersion: manager.IndexFile.APIVersion,
+					Generated:  manager.IndexFile.Generated,
+					Entries:    make(map[string]repo.ChartVersions),
+				}
+			}
+
+			relativeRoot, err := filepath.Rel(filepath.Join(manager.ShardsPath, vendor), repoRoot)
+			if err != nil {
+				return nil, err
+			}
+			shardVersion := *chartVersion
+			shardVersion.URLs = make([]string, 0, len(chartVersion.URLs))
+			for _, url := range chartVersion.URLs {
+				if !strings.Contains(url, "://") {
+					url = path.Join(filepath.ToSlash(relativeRoot), url)
+				}
+				shardVersion.URLs = append(shardVersion.URLs, url)
+			}
+			shards[vendor].Entries[chartName] = append(shards[vendor].Entries[chartName], &shardVersion)
+		}
+	}
+
+	files := make(map[string][]byte)
+	shardIndex := ShardIndex{
+		APIVersion: manager.IndexFile.APIVersion,
+		Generated:  manager.IndexFile.Generated,
+		Shards:     make(map[string]Shard),
+	}
+	for vendor, shard := range shards {
+		shard.SortEntries()
+		data, err := yaml.Marshal(shard)
+		if err != nil {
+			return nil, err
+		}
+		shardPath := filepath.Join(manager.ShardsPath, vendor, shardFile)
+		files[shardPath] = data
+
+		relativePath, err := filepath.Rel(repoRoot, shardPath)
+		if err != nil {
+			return nil, err
+		}
+		chartNames := make([]string, 0, len(shard.Entries))
+		for chartName := range shard.Entries {
+			chartNames = append(chartNames, chartName)
+		}
+		sort.Strings(chartNames)
+		shardIndex.Shards[vendor] = Shard{
+			Path:   filepath.ToSlash(relativePath),
+			Digest: fmt.Sprintf("%x", sha256.Sum256(data)),
+			Charts: chartNames,
+		}
+	}
+
+	data, err := yaml.Marshal(shardIndex)
+	if err != nil {
+		return nil, err
+	}
+	files[filepath.Join(manager.ShardsPath, ShardsFile)] = data
+
+	return files, nil
+}
+
+// Lists shard files present in the shards directory
+func (manager *Manager) listShardFiles() ([]string, error) {
+	shardFiles, err := filepath.Glob(filepath.Join(manager.ShardsPath, "*", shardFile))
+	if err != nil {
+		return nil, err
+	}
+	shardsFile := filepath.Join(manager.ShardsPath, ShardsFile)
+	if _, err := os.Stat(shardsFile); err == nil {
+		shardFiles = append(shardFiles, shardsFile)
+	}
+	sort.Strings(shardFiles)
+
+	return shardFiles, nil
+}
+
+// Atomically writes data to a file if its contents differ. Returns true if
+// the file was written
+func writeIfChanged(filePath string, data []byte) (bool, error) {
+	if current, err := os.ReadFile(filePath); err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return false, err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath))
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err = tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return false, err
+	}
+	if err = tempFile.Close(); err != nil {
+		return false, err
+	}
+	if err = os.Chmod(tempFile.Name(), 0644); err != nil {
+		return false, err
+	}
+
+	return true, os.Rename(tempFile.Name(), filePath)
+}
+
+// Writes the index shards that differ from the index and removes shards of
+// vendors no longer in the index
+func (manager *Manager) writeShards() error {
+	files, err := manager.generateShards()
+	if err != nil {
+		return err
+	}
+
+	for filePath, data := range files {
+		written, err := writeIfChanged(filePath, data)
+		if err != nil {
+			return err
+		}
+		if written {
+			logrus.Debugf("Wrote index shard %s\n", filePath)
+		}
+	}
+
+	shardFiles, err := manager.listShardFiles()
+	if err != nil {
+		return err
+	}
+	for _, existingFile := range shardFiles {
+		if _, ok := files[existingFile]; ok {
+			continue
+		}
+		logrus.Debugf("Removing index shard %s\n", existingFile)
+		if err := os.Remove(existingFile); err != nil {
+			return err
+		}
+		os.Remove(filepath.Dir(existingFile))
+	}
+
+	return nil
+}
+
+// Compares the index shards on disk with the index
+func (manager *Manager) checkShards() ([]Issue, error) {
+	files, err := manager.generateShards()
+	if err != nil {
+		return nil, err
+	}
+
+	repoRoot := filepath.Dir(manager.indexPath)
+	relativePath := func(filePath string) string {
+		relativePath, err := filepath.Rel(repoRoot, filePath)
+		if err != nil {
+			return filePath
+		}
+		return filepath.ToSlash(relativePath)
+	}
+
+	issues := make([]Issue, 0)
+	for filePath, data := range files {
+		current, err := os.ReadFile(filePath)
+		if os.IsNotExist(err) {
+			issues = append(issues, Issue{
+				Kind:    IssueMissingShard,
+				Path:    relativePath(filePath),
+				Message: "index shard is missing",
+			})
+		} else if err != nil {
+			return nil, err
+		} else if !bytes.Equal(current, data) {
+			issues = append(issues, Issue{
+				Kind:    IssueStaleShard,
+				Path:    relativePath(filePath),
+				Message: "index shard does not match index",
+			})
+		}
+	}
+
+	shardFiles, err := manager.listShardFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, existingFile := range shardFiles {
+		if _, ok := files[existingFile]; !ok {
+			issues = append(issues, Issue{
+				Kind:    IssueOrphanedShard,
+				Path:    relativePath(existingFile),
+				Message: "index shard has no charts in index",
+			})
+		}
+	}
+
+	return issues, nil
+}
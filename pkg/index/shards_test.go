@@ -0,0 +1,228 @@
+package index
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Returns a manager writing shards to shards/ with the given assets indexed,
+// each given as vendor/name/version, and an entry for a chart hosted elsewhere
+func testShardManager(t *testing.T, assets ...[3]string) (*Manager, string) {
+	t.Helper()
+	repoRoot := t.TempDir()
+	manager, err := Load(filepath.Join(repoRoot, "index.yaml"), filepath.Join(repoRoot, "assets"), "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.ShardsPath = filepath.Join(repoRoot, "shards")
+	for _, asset := range assets {
+		if err = manager.Add(saveTestAsset(t, repoRoot, asset[0], asset[1], asset[2], nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manager.IndexFile.Entries["remote"] = repo.ChartVersions{{
+		Metadata: &chart.Metadata{Name: "remote", Version: "1.0.0"},
+		URLs:     []string{"https://charts.example.com/remote-1.0.0.tgz"},
+	}}
+
+	return manager, repoRoot
+}
+
+// Reads the index of shards
+func readShardIndex(t *testing.T, manager *Manager) ShardIndex {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(manager.ShardsPath, ShardsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var shardIndex ShardIndex
+	if err = yaml.Unmarshal(data, &shardIndex); err != nil {
+		t.Fatal(err)
+	}
+
+	return shardIndex
+}
+
+func TestWriteShards(t *testing.T) {
+	manager, repoRoot := testShardManager(t,
+		[3]string{"acme", "foo", "1.0.0"},
+		[3]string{"acme", "foo", "1.1.0"},
+		[3]string{"acme", "bar", "0.1.0"},
+		[3]string{"globex", "baz", "2.0.0"},
+	)
+	if err := manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	acmeShard, err := repo.LoadIndexFile(filepath.Join(repoRoot, "shards", "acme", "index.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acmeShard.Entries) != 2 || len(acmeShard.Entries["foo"]) != 2 || len(acmeShard.Entries["bar"]) != 1 {
+		t.Errorf("expected foo and bar in acme shard, got %+v", acmeShard.Entries)
+	}
+	fooVersion, err := acmeShard.Get("foo", "1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fooVersion.URLs, []string{"../../assets/acme/foo-1.1.0.tgz"}) {
+		t.Errorf("expected URL relative to shard, got %v", fooVersion.URLs)
+	}
+	if indexVersion, _ := manager.IndexFile.Get("foo", "1.1.0"); !reflect.DeepEqual(indexVersion.URLs, []string{"assets/acme/foo-1.1.0.tgz"}) {
+		t.Errorf("expected index URLs to be unchanged, got %v", indexVersion.URLs)
+	}
+
+	shardIndex := readShardIndex(t, manager)
+	if len(shardIndex.Shards) != 2 {
+		t.Fatalf("expected shards of acme and globex, got %+v", shardIndex.Shards)
+	}
+	for vendor, charts := range map[string][]string{"acme": {"bar", "foo"}, "globex": {"baz"}} {
+		shard := shardIndex.Shards[vendor]
+		expectedPath := fmt.Sprintf("shards/%s/index.yaml", vendor)
+		if shard.Path != expectedPath {
+			t.Errorf("expected %s shard path %s, got %s", vendor, expectedPath, shard.Path)
+		}
+		if !reflect.DeepEqual(shard.Charts, charts) {
+			t.Errorf("expected %s charts %v, got %v", vendor, charts, shard.Charts)
+		}
+		data, err := os.ReadFile(filepath.Join(repoRoot, filepath.FromSlash(shard.Path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digest := fmt.Sprintf("%x", sha256.Sum256(data)); shard.Digest != digest {
+			t.Errorf("expected %s shard digest %s, got %s", vendor, digest, shard.Digest)
+		}
+	}
+	if !shardIndex.Generated.Equal(manager.IndexFile.Generated) {
+		t.Errorf("expected shards generated at %s, got %s", manager.IndexFile.Generated, shardIndex.Generated)
+	}
+
+	issues, err := manager.checkShards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("expected written shards to match index, got %+v", issues)
+	}
+}
+
+func TestWriteShardsUnchanged(t *testing.T) {
+	manager, repoRoot := testShardManager(t, [3]string{"acme", "foo", "1.0.0"})
+	if err := manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	shardPath := filepath.Join(repoRoot, "shards", "acme", "index.yaml")
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(shardPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(shardPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Errorf("shard rewritten by no-op write, modified at %s", info.ModTime())
+	}
+}
+
+func TestWriteShardsRemovesVendor(t *testing.T) {
+	manager, repoRoot := testShardManager(t,
+		[3]string{"acme", "foo", "1.0.0"},
+		[3]string{"globex", "baz", "2.0.0"},
+	)
+	if err := manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Remove("baz", "2.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(repoRoot, "shards", "globex")); !os.IsNotExist(err) {
+		t.Errorf("expected globex shard directory to be removed, got %v", err)
+	}
+	if shardIndex := readShardIndex(t, manager); len(shardIndex.Shards) != 1 || shardIndex.Shards["acme"].Path != "shards/acme/index.yaml" {
+		t.Errorf("expected only acme shard to be listed, got %+v", shardIndex.Shards)
+	}
+}
+
+func TestCheckShards(t *testing.T) {
+	manager, repoRoot := testShardManager(t,
+		[3]string{"acme", "foo", "1.0.0"},
+		[3]string{"globex", "baz", "2.0.0"},
+	)
+	if err := manager.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(repoRoot, "shards", "acme", "index.yaml"), []byte("apiVersion: v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(repoRoot, "shards", "globex", "index.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(repoRoot, "shards", "initech"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoRoot, "shards", "initech", "index.yaml"), []byte("apiVersion: v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	issues, err := manager.checkShards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		IssueStaleShard:    "shards/acme/index.yaml",
+		IssueMissingShard:  "shards/globex/index.yaml",
+		IssueOrphanedShard: "shards/initech/index.yaml",
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %+v", len(expected), issues)
+	}
+	for kind, issuePath := range expected {
+		if matched := issuesOfKind(issues, kind); len(matched) != 1 || matched[0].Path != issuePath {
+			t.Errorf("expected %s issue for %s, got %+v", kind, issuePath, matched)
+		}
+	}
+}
+
+func TestAssetVendor(t *testing.T) {
+	tests := []struct {
+		name     string
+		urls     []string
+		expected string
+	}{
+		{"vendor asset", []string{"assets/acme/foo-1.0.0.tgz"}, "acme"},
+		{"no urls", nil, ""},
+		{"not under vendor", []string{"assets/foo-1.0.0.tgz"}, ""},
+		{"nested", []string{"assets/acme/foo/foo-1.0.0.tgz"}, ""},
+		{"empty vendor", []string{"assets//foo-1.0.0.tgz"}, ""},
+		{"remote", []string{"https://charts.example.com/foo-1.0.0.tgz"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chartVersion := &repo.ChartVersion{Metadata: &chart.Metadata{Name: "foo"}, URLs: test.urls}
+			if vendor := AssetVendor(chartVersion, "assets"); vendor != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, vendor)
+			}
+		})
+	}
+}
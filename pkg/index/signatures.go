@@ -0,0 +1,50 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/samuelattwood/partner-charts-ci/pkg/sign"
+	"github.com/sirupsen/logrus"
+)
+
+// Writes the asset checksums file and signs it and the index. Existing
+// signatures that are valid for the signing key are kept, so that nothing is
+// rewritten when the index and assets are unchanged
+func (manager *Manager) writeSignatures() error {
+	checksums, err := sign.Checksums(manager.assetsPath)
+	if err != nil {
+		return err
+	}
+	checksumsPath := filepath.Join(manager.assetsPath, sign.ChecksumsFile)
+	written, err := writeIfChanged(checksumsPath, checksums)
+	if err != nil {
+		return err
+	}
+	if written {
+		logrus.Debugf("Wrote %s\n", checksumsPath)
+	}
+
+	for _, filePath := range []string{manager.indexPath, checksumsPath} {
+		signaturePath := filePath + manager.Signer.Extension()
+		if err := sign.VerifyFile(manager.Signer, filePath); err == nil {
+			logrus.Debugf("%s is up to date\n", signaturePath)
+			continue
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		signature, err := manager.Signer.Sign(data)
+		if err != nil {
+			return err
+		}
+		if _, err = writeIfChanged(signaturePath, signature); err != nil {
+			return err
+		}
+		logrus.Infof("Signed %s\n", filePath)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
+	"github.com/samuelattwood/partner-charts-ci/pkg/fetcher"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+)
+
+type Finding struct {
+	Severity string
+	Message  string
+}
+
+func warning(format string, args ...interface{}) Finding {
+	return Finding{
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+func errorf(format string, args ...interface{}) Finding {
+	return Finding{
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Checks a stored chart for issues that should be addressed in its package
+func Chart(helmChart *chart.Chart) []Finding {
+	findings := make([]Finding, 0)
+
+	if conform.IsGeneratedAppReadme(helmChart) {
+		findings = append(findings, warning("%s was generated from README.md. Consider adding one to the package overlay", conform.AppReadmeFile))
+	}
+
+	return findings
+}
+
+// Checks that the chart icon can be loaded and is a supported image. Icons
+// stored in the repository are read relative to repoRoot
+func Icon(helmChart *chart.Chart, repoRoot string, maxSize int64) []Finding {
+	iconURL := helmChart.Metadata.Icon
+	switch {
+	case iconURL == "":
+		return []Finding{warning("no icon set")}
+	case strings.HasPrefix(iconURL, "http://"):
+		return []Finding{errorf("icon %s is not served over HTTPS", iconURL)}
+	case strings.HasPrefix(iconURL, "file://"):
+		iconPath := filepath.Join(repoRoot, filepath.FromSlash(strings.TrimPrefix(iconURL, "file://")))
+		rel, err := filepath.Rel(repoRoot, iconPath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return []Finding{errorf("icon %s is outside of the repository", iconURL)}
+		}
+		data, err := os.ReadFile(iconPath)
+		if err == nil {
+			_, err = fetcher.ReadIcon(data, maxSize)
+		}
+		if err != nil {
+			return []Finding{errorf("icon %s: %s", iconURL, err)}
+		}
+	default:
+		if _, err := fetcher.FetchIcon(iconURL, maxSize); err != nil {
+			if strings.HasPrefix(iconURL, "data:") {
+				iconURL = "data URI"
+			}
+			return []Finding{errorf("icon %s: %s", iconURL, err)}
+		}
+	}
+
+	return nil
+}
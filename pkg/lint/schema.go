@@ -0,0 +1,285 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
+	"github.com/sirupsen/logrus"
+	"github.com/xeipuuv/gojsonschema"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const (
+	annotationKubeVersion = "catalog.cattle.io/kube-version"
+	annotationNamespace   = "catalog.cattle.io/namespace"
+)
+
+// Schema directory suffixes in order of preference, as published in
+// yannh/kubernetes-json-schema
+var schemaDirSuffixes = []string{"-standalone-strict", "-standalone", ""}
+
+// KubeSchemas provides Kubernetes JSON schemas read from a local directory
+// containing one v<version>-standalone-strict directory per Kubernetes version
+type KubeSchemas struct {
+	//versions are the available Kubernetes versions in ascending order
+	versions []*semver.Version
+	//dirs maps a Kubernetes version to its schema directory
+	dirs map[string]string
+	//schemas caches loaded schemas by file path, nil if not found
+	schemas map[string]*gojsonschema.Schema
+}
+
+// Reads the available Kubernetes versions from a schema directory
+func LoadKubeSchemas(schemaPath string) (*KubeSchemas, error) {
+	entries, err := os.ReadDir(schemaPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Kubernetes schemas not found: %s does not exist", schemaPath)
+	} else if err != nil {
+		return nil, err
+	}
+
+	kubeSchemas := &KubeSchemas{
+		dirs:    make(map[string]string),
+		schemas: make(map[string]*gojsonschema.Schema),
+	}
+	preference := make(map[string]int)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		for i, suffix := range schemaDirSuffixes {
+			name := strings.TrimSuffix(entry.Name(), suffix)
+			if name == entry.Name() && suffix != "" {
+				continue
+			}
+			version, err := semver.NewVersion(name)
+			if err != nil || !strings.HasPrefix(name, "v") || version.Prerelease() != "" {
+				break
+			}
+			key := "v" + version.String()
+			if current, ok := preference[key]; ok && current <= i {
+				break
+			}
+			if _, ok := preference[key]; !ok {
+				kubeSchemas.versions = append(kubeSchemas.versions, version)
+			}
+			preference[key] = i
+			kubeSchemas.dirs[key] = filepath.Join(schemaPath, entry.Name())
+			break
+		}
+	}
+
+	if len(kubeSchemas.versions) == 0 {
+		return nil, fmt.Errorf("Kubernetes schemas not found: no v<version> directories in %s", schemaPath)
+	}
+	sort.Sort(semver.Collection(kubeSchemas.versions))
+
+	return kubeSchemas, nil
+}
+
+// Returns the schema for a resource in the given Kubernetes version, using the
+// file naming of kubeconform. Returns nil if no schema exists
+func (kubeSchemas *KubeSchemas) schema(kubeVersion, apiVersion, kind string) (*gojsonschema.Schema, error) {
+	dir, ok := kubeSchemas.dirs[kubeVersion]
+	if !ok {
+		return nil, fmt.Errorf("no schemas for Kubernetes %s", kubeVersion)
+	}
+
+	groupVersion := strings.Split(apiVersion, "/")
+	kindSuffix := "-" + strings.ToLower(strings.Split(groupVersion[0], ".")[0])
+	if len(groupVersion) > 1 {
+		kindSuffix += "-" + strings.ToLower(groupVersion[1])
+	}
+	schemaPath := filepath.Join(dir, strings.ToLower(kind)+kindSuffix+".json")
+
+	if schema, ok := kubeSchemas.schemas[schemaPath]; ok {
+		return schema, nil
+	}
+
+	schemaFile, err := os.ReadFile(schemaPath)
+	if os.IsNotExist(err) {
+		kubeSchemas.schemas[schemaPath] = nil
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schemaFile))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", schemaPath, err)
+	}
+	kubeSchemas.schemas[schemaPath] = schema
+
+	return schema, nil
+}
+
+// Returns true if any available Kubernetes version has a schema for the resource
+func (kubeSchemas *KubeSchemas) known(apiVersion, kind string) bool {
+	for _, version := range kubeSchemas.versions {
+		if schema, err := kubeSchemas.schema("v"+version.String(), apiVersion, kind); err == nil && schema != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the highest Kubernetes minor version removing a known API
+func latestRemovedMinor() int {
+	latest := 0
+	for _, api := range conform.DeprecatedAPIs {
+		if api.RemovedIn > latest {
+			latest = api.RemovedIn
+		}
+	}
+
+	return latest
+}
+
+// Returns the lowest and highest Kubernetes versions satisfying the constraint.
+// Candidates are the schema versions if available, otherwise each 1.x minor
+// release up to at least the latest release removing a known API, so that
+// removals are reported for charts without an upper bound
+func supportedKubeVersions(constraint string, kubeSchemas *KubeSchemas) []string {
+	candidates := make([]string, 0)
+	if kubeSchemas != nil {
+		for _, version := range kubeSchemas.versions {
+			candidates = append(candidates, "v"+version.String())
+		}
+	} else {
+		latest := latestKubeMinor(constraint)
+		if removed := latestRemovedMinor(); removed > latest {
+			latest = removed
+		}
+		for minor := 0; minor <= latest; minor++ {
+			candidates = append(candidates, fmt.Sprintf("v1.%d.0", minor))
+		}
+	}
+
+	supported := make([]string, 0)
+	for _, candidate := range candidates {
+		if constraint == "" || chartutil.IsCompatibleRange(constraint, candidate) {
+			supported = append(supported, candidate)
+		}
+	}
+
+	if len(supported) > 1 {
+		return []string{supported[0], supported[len(supported)-1]}
+	}
+
+	return supported
+}
+
+// Returns the API groups of custom resources defined by the chart
+func providedGroups(manifests []conform.Manifest) map[string]bool {
+	groups := make(map[string]bool)
+	for _, manifest := range manifests {
+		if manifest.Kind() != "CustomResourceDefinition" {
+			continue
+		}
+		if spec, ok := manifest.Object["spec"].(map[string]interface{}); ok {
+			if group, ok := spec["group"].(string); ok {
+				groups[group] = true
+			}
+		}
+	}
+
+	return groups
+}
+
+// Checks manifests rendered against a single Kubernetes version
+func checkKubeVersion(helmChart *chart.Chart, namespace, kubeVersion string, kubeSchemas *KubeSchemas) []Finding {
+	rendered, err := conform.RenderChart(helmChart, namespace, kubeVersion)
+	if err != nil {
+		return []Finding{errorf("unable to render against Kubernetes %s: %s", kubeVersion, err)}
+	}
+	minor, err := conform.KubeMinorVersion(kubeVersion)
+	if err != nil {
+		return []Finding{errorf("invalid Kubernetes version %s: %s", kubeVersion, err)}
+	}
+
+	findings := make([]Finding, 0)
+	manifests := conform.ParseManifests(helmChart, rendered)
+	groups := providedGroups(manifests)
+	for _, manifest := range manifests {
+		apiVersion, kind := manifest.APIVersion(), manifest.Kind()
+		resource := fmt.Sprintf("%s %s (%s) in %s", kind, manifest.Name(), apiVersion, manifest.Source)
+
+		deprecatedAPI, deprecated := conform.FindDeprecatedAPI(apiVersion, kind)
+		if deprecated && deprecatedAPI.RemovedIn <= minor {
+			findings = append(findings, errorf("%s is removed in Kubernetes v1.%d and not served by %s. Use %s",
+				resource, deprecatedAPI.RemovedIn, kubeVersion, deprecatedAPI.Replacement))
+			continue
+		} else if deprecated && deprecatedAPI.DeprecatedIn <= minor {
+			findings = append(findings, warning("%s is deprecated since Kubernetes v1.%d and removed in v1.%d. Use %s",
+				resource, deprecatedAPI.DeprecatedIn, deprecatedAPI.RemovedIn, deprecatedAPI.Replacement))
+		}
+
+		if kubeSchemas == nil {
+			continue
+		}
+
+		schema, err := kubeSchemas.schema(kubeVersion, apiVersion, kind)
+		if err != nil {
+			findings = append(findings, errorf("%s: %s", resource, err))
+			continue
+		}
+		if schema == nil {
+			group := strings.Split(apiVersion, "/")[0]
+			if !groups[group] && kubeSchemas.known(apiVersion, kind) {
+				findings = append(findings, errorf("%s is not served by Kubernetes %s", resource, kubeVersion))
+			} else {
+				logrus.Debugf("No schema for %s in Kubernetes %s\n", resource, kubeVersion)
+			}
+			continue
+		}
+
+		result, err := schema.Validate(gojsonschema.NewGoLoader(manifest.Object))
+		if err != nil {
+			findings = append(findings, errorf("%s: %s", resource, err))
+			continue
+		}
+		for _, resultError := range result.Errors() {
+			findings = append(findings, errorf("%s is invalid for Kubernetes %s: %s", resource, kubeVersion, resultError))
+		}
+	}
+
+	return findings
+}
+
+// Validates manifests rendered with default values against the lowest and
+// highest Kubernetes versions supported by the chart. Reports deprecated and
+// removed APIs, and schema violations if Kubernetes schemas are provided
+func KubeAPIs(helmChart *chart.Chart, kubeSchemas *KubeSchemas) []Finding {
+	constraint := helmChart.Metadata.KubeVersion
+	if kubeVersion, ok := helmChart.Metadata.Annotations[annotationKubeVersion]; ok {
+		constraint = kubeVersion
+	}
+	namespace := helmChart.Metadata.Annotations[annotationNamespace]
+
+	kubeVersions := supportedKubeVersions(constraint, kubeSchemas)
+	if len(kubeVersions) == 0 {
+		return []Finding{errorf("no Kubernetes version satisfies kubeVersion '%s'", constraint)}
+	}
+
+	findings := make([]Finding, 0)
+	reported := make(map[string]bool)
+	for _, kubeVersion := range kubeVersions {
+		for _, finding := range checkKubeVersion(helmChart, namespace, kubeVersion, kubeSchemas) {
+			if reported[finding.Message] {
+				continue
+			}
+			reported[finding.Message] = true
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,105 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// Returns a chart rendering the given manifest with the given kubeVersion
+func testManifestChart(kubeVersion, manifest string) *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:  chart.APIVersionV2,
+			Name:        "foo",
+			Version:     "1.0.0",
+			KubeVersion: kubeVersion,
+		},
+		Templates: []*chart.File{
+			{Name: "templates/manifest.yaml", Data: []byte(manifest)},
+		},
+	}
+}
+
+// Returns the findings of the given severity
+func findingsOfSeverity(findings []Finding, severity string) []Finding {
+	matched := make([]Finding, 0)
+	for _, finding := range findings {
+		if finding.Severity == severity {
+			matched = append(matched, finding)
+		}
+	}
+
+	return matched
+}
+
+func TestLoadKubeSchemasNotFound(t *testing.T) {
+	emptyDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(emptyDir, "unrelated"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, schemaPath := range []string{filepath.Join(t.TempDir(), "missing"), emptyDir} {
+		_, err := LoadKubeSchemas(schemaPath)
+		if err == nil || !strings.Contains(err.Error(), "schemas not found") {
+			t.Errorf("%s: expected schemas not found error, got %v", schemaPath, err)
+		}
+	}
+}
+
+func TestKubeAPIs(t *testing.T) {
+	ingress := "apiVersion: extensions/v1beta1\nkind: Ingress\nmetadata:\n  name: foo\n"
+	tests := []struct {
+		name        string
+		kubeVersion string
+		errors      int
+		warnings    int
+	}{
+		{name: "removed API", kubeVersion: ">=1.20.0-0", errors: 1, warnings: 1},
+		{name: "deprecated API", kubeVersion: ">=1.16.0-0 <1.22.0-0", warnings: 1},
+		{name: "served API", kubeVersion: "<1.14.0-0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings := KubeAPIs(testManifestChart(test.kubeVersion, ingress), nil)
+			if errors := findingsOfSeverity(findings, SeverityError); len(errors) != test.errors {
+				t.Errorf("expected %d errors, got %v", test.errors, errors)
+			}
+			if warnings := findingsOfSeverity(findings, SeverityWarning); len(warnings) != test.warnings {
+				t.Errorf("expected %d warnings, got %v", test.warnings, warnings)
+			}
+		})
+	}
+}
+
+func TestKubeAPIsWithSchemas(t *testing.T) {
+	schemaPath := t.TempDir()
+	schema := `{"type": "object", "required": ["data"], "properties": {"data": {"type": "object"}}}`
+	for _, version := range []string{"v1.25.0", "v1.27.0"} {
+		dir := filepath.Join(schemaPath, version+"-standalone-strict")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "configmap-v1.json"), []byte(schema), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kubeSchemas, err := LoadKubeSchemas(schemaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\ndata: {}\n"
+	if findings := KubeAPIs(testManifestChart("", valid), kubeSchemas); len(findings) != 0 {
+		t.Errorf("expected no findings, got %v", findings)
+	}
+
+	invalid := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n"
+	if errors := findingsOfSeverity(KubeAPIs(testManifestChart("", invalid), kubeSchemas), SeverityError); len(errors) != 2 {
+		t.Errorf("expected an error for each Kubernetes version, got %v", errors)
+	}
+}
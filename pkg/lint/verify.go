@@ -0,0 +1,146 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/lint/rules"
+	"helm.sh/helm/v3/pkg/lint/support"
+	"helm.sh/helm/v3/pkg/releaseutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Matches the Kubernetes minor versions referenced in a kubeVersion constraint
+var constraintMinorPattern = regexp.MustCompile(`(?:^|[^\d.])v?1\.(\d+)`)
+
+// Returns the newest Kubernetes minor version to consider for a kubeVersion
+// constraint. This is one past the newest minor version referenced by the
+// constraint or the Helm default, whichever is newer, so that charts requiring
+// Kubernetes releases newer than this tool are still rendered
+func latestKubeMinor(constraint string) int {
+	latest, err := strconv.Atoi(chartutil.DefaultCapabilities.KubeVersion.Minor)
+	if err != nil {
+		latest = 0
+	}
+	for _, match := range constraintMinorPattern.FindAllStringSubmatch(constraint, -1) {
+		if minor, err := strconv.Atoi(match[1]); err == nil && minor > latest {
+			latest = minor
+		}
+	}
+
+	return latest + 1
+}
+
+// Returns the Kubernetes version to render a chart against. The Helm default
+// is used if it satisfies the constraint, otherwise the closest minor release
+// that does
+func RenderKubeVersion(constraint string) (string, error) {
+	defaultVersion := chartutil.DefaultCapabilities.KubeVersion
+	if constraint == "" || chartutil.IsCompatibleRange(constraint, defaultVersion.Version) {
+		return defaultVersion.Version, nil
+	}
+
+	defaultMinor, err := strconv.Atoi(defaultVersion.Minor)
+	if err != nil {
+		return "", err
+	}
+
+	for minor := defaultMinor + 1; minor <= latestKubeMinor(constraint); minor++ {
+		version := fmt.Sprintf("v1.%d.0", minor)
+		if chartutil.IsCompatibleRange(constraint, version) {
+			return version, nil
+		}
+	}
+	for minor := defaultMinor - 1; minor >= 0; minor-- {
+		version := fmt.Sprintf("v1.%d.0", minor)
+		if chartutil.IsCompatibleRange(constraint, version) {
+			return version, nil
+		}
+	}
+
+	return "", fmt.Errorf("no Kubernetes version satisfies kubeVersion '%s'", constraint)
+}
+
+// Runs the Helm lint rules for Chart.yaml, values, and dependencies against
+// the exported chart directory and returns the first error
+func lintChartDirectory(helmChart *chart.Chart) error {
+	tempDir, err := os.MkdirTemp("", "lintDir")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	chartDir := filepath.Join(tempDir, helmChart.Name())
+	if err = conform.ExportChartDirectory(helmChart, chartDir); err != nil {
+		return err
+	}
+
+	linter := support.Linter{ChartDir: chartDir}
+	rules.Chartfile(&linter)
+	rules.ValuesWithOverrides(&linter, nil)
+	rules.Dependencies(&linter)
+
+	for _, message := range linter.Messages {
+		if message.Severity == support.ErrorSev {
+			return message
+		}
+	}
+
+	return nil
+}
+
+// Ensures rendered resources are valid YAML
+func validateRendered(rendered map[string]string) error {
+	fileNames := make([]string, 0, len(rendered))
+	for fileName := range rendered {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	for _, fileName := range fileNames {
+		if ext := path.Ext(fileName); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		for _, document := range releaseutil.SplitManifests(rendered[fileName]) {
+			object := make(map[string]interface{})
+			if err := yaml.Unmarshal([]byte(document), &object); err != nil {
+				return fmt.Errorf("%s: %w", fileName, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Lints the chart and renders its templates with default values against a
+// Kubernetes version satisfying its kubeVersion. Returns the first error found
+func Verify(helmChart *chart.Chart, namespace string) error {
+	if err := lintChartDirectory(helmChart); err != nil {
+		return fmt.Errorf("lint %s (%s): %w", helmChart.Name(), helmChart.Metadata.Version, err)
+	}
+
+	kubeVersion, err := RenderKubeVersion(helmChart.Metadata.KubeVersion)
+	if err != nil {
+		return fmt.Errorf("render %s (%s): %w", helmChart.Name(), helmChart.Metadata.Version, err)
+	}
+
+	rendered, err := conform.RenderChart(helmChart, namespace, kubeVersion)
+	if err == nil {
+		err = validateRendered(rendered)
+	}
+	if err != nil {
+		return fmt.Errorf("render %s (%s) against Kubernetes %s: %w", helmChart.Name(), helmChart.Metadata.Version, kubeVersion, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestRenderKubeVersion(t *testing.T) {
+	defaultVersion := chartutil.DefaultCapabilities.KubeVersion.Version
+	defaultMinor, err := strconv.Atoi(chartutil.DefaultCapabilities.KubeVersion.Minor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		constraint string
+		expected   string
+		fails      bool
+	}{
+		{constraint: "", expected: defaultVersion},
+		{constraint: ">=1.16.0-0", expected: defaultVersion},
+		{constraint: "<1.20.0-0", expected: "v1.19.0"},
+		{constraint: fmt.Sprintf(">=1.%d.0-0", defaultMinor+2), expected: fmt.Sprintf("v1.%d.0", defaultMinor+2)},
+		{constraint: ">=1.40.0-0", expected: "v1.40.0"},
+		{constraint: ">v1.45", expected: "v1.46.0"},
+		{constraint: ">=1.36.0-0 <1.38.0-0", expected: "v1.36.0"},
+		{constraint: ">=2.0.0", fails: true},
+	}
+
+	for _, test := range tests {
+		version, err := RenderKubeVersion(test.constraint)
+		if test.fails {
+			if err == nil {
+				t.Errorf("'%s': expected error, got %s", test.constraint, version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("'%s': %s", test.constraint, err)
+		} else if version != test.expected {
+			t.Errorf("'%s': expected %s, got %s", test.constraint, test.expected, version)
+		}
+	}
+}
+
+func TestSupportedKubeVersionsWithoutSchemas(t *testing.T) {
+	supported := supportedKubeVersions(">=1.40.0-0 <1.42.0-0", nil)
+	if strings.Join(supported, ",") != "v1.40.0,v1.41.0" {
+		t.Errorf("expected v1.40.0,v1.41.0, got %v", supported)
+	}
+}
+
+func TestVerifyOutsideWorkingDirectory(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	tempDir := t.TempDir()
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       "foo",
+			Version:    "1.0.0",
+		},
+		Templates: []*chart.File{
+			{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n")},
+		},
+	}
+	if err := Verify(helmChart, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("expected nothing written to the working directory, found %s", entries[0].Name())
+	}
+}
@@ -0,0 +1,155 @@
+package oci
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// Publisher pushes chart assets to a repository in an OCI registry. Registry
+// credentials are read from the Helm registry configuration, as set by
+// 'helm registry login'
+type Publisher struct {
+	client *registry.Client
+	//repository is the registry host and path charts are pushed under
+	repository string
+}
+
+// plainHTTPTransport sends requests to registries on localhost over plain
+// HTTP, rather than relying on the fallbacks of the Helm registry client
+type plainHTTPTransport struct {
+	base http.RoundTripper
+}
+
+func (transport plainHTTPTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Scheme == "https" && isLocalhost(request.URL.Hostname()) {
+		request = request.Clone(request.Context())
+		request.URL.Scheme = "http"
+	}
+
+	return transport.base.RoundTrip(request)
+}
+
+// Returns true if host is localhost or a loopback address
+func isLocalhost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// Returns the canonical form of a chart version, so that versions such as
+// v1.2.3 and 1.0 match the tags listed by the registry. Versions that are not
+// semantic versions are returned unchanged
+func normalizeVersion(version string) string {
+	semVer, err := semver.NewVersion(version)
+	if err != nil {
+		return version
+	}
+
+	return semVer.String()
+}
+
+// Creates a publisher for an OCI repository such as
+// oci://registry.example.com/charts. Registries on localhost are accessed over
+// plain HTTP
+func NewPublisher(repository string) (*Publisher, error) {
+	repository = strings.TrimSuffix(strings.TrimPrefix(repository, fmt.Sprintf("%s://", registry.OCIScheme)), "/")
+	if repository == "" || strings.Contains(repository, "://") {
+		return nil, fmt.Errorf("invalid OCI repository '%s'", repository)
+	}
+
+	client, err := registry.NewClient(
+		registry.ClientOptEnableCache(true),
+		registry.ClientOptHTTPClient(&http.Client{Transport: plainHTTPTransport{base: http.DefaultTransport}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Publisher{
+		client:     client,
+		repository: repository,
+	}, nil
+}
+
+// Returns the registry reference of a chart, without a tag
+func (publisher *Publisher) chartReference(chartName string) string {
+	return fmt.Sprintf("%s/%s", publisher.repository, chartName)
+}
+
+// Returns the normalized versions of a chart already in the registry. A chart
+// that has never been pushed has no versions
+func (publisher *Publisher) Tags(chartName string) (map[string]bool, error) {
+	versions := make(map[string]bool)
+	tags, err := publisher.client.Tags(publisher.chartReference(chartName))
+	if err != nil {
+		errorMessage := strings.ToLower(err.Error())
+		if strings.Contains(errorMessage, "not found") || strings.Contains(errorMessage, "name unknown") {
+			logrus.Debugf("%s not found in registry\n", publisher.chartReference(chartName))
+			return versions, nil
+		}
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		versions[normalizeVersion(tag)] = true
+	}
+
+	return versions, nil
+}
+
+// Returns the chart versions not yet in the registry
+func (publisher *Publisher) MissingVersions(chartName string, chartVersions repo.ChartVersions) (repo.ChartVersions, error) {
+	tags, err := publisher.Tags(chartName)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make(repo.ChartVersions, 0)
+	for _, chartVersion := range chartVersions {
+		if !tags[normalizeVersion(chartVersion.Version)] {
+			missing = append(missing, chartVersion)
+		}
+	}
+
+	return missing, nil
+}
+
+// Pushes a chart asset to the registry as <repository>/<chart>:<version>,
+// tagged with the normalized version as the registry only lists tags that are
+// strict semantic versions. Chart annotations are added to the OCI manifest
+// annotations. Returns the pushed reference
+func (publisher *Publisher) Push(assetPath string) (string, error) {
+	helmChart, err := loader.Load(assetPath)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(assetPath)
+	if err != nil {
+		return "", err
+	}
+
+	version := normalizeVersion(helmChart.Metadata.Version)
+	ref := fmt.Sprintf("%s:%s", publisher.chartReference(helmChart.Name()), version)
+	result, err := publisher.client.Push(data, ref, registry.PushOptStrictMode(version == helmChart.Metadata.Version))
+	if err != nil {
+		return "", fmt.Errorf("unable to push %s: %w", ref, err)
+	}
+
+	logrus.Debugf("Pushed %s with digest %s\n", result.Ref, result.Manifest.Digest)
+
+	return result.Ref, nil
+}
@@ -0,0 +1,345 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+var registryPath = regexp.MustCompile(`^/v2/(.+)/(blobs/uploads/[^/]*|blobs/[^/]+|manifests/[^/]+|tags/list)$`)
+
+// testRegistry is an in-memory OCI distribution registry, implementing the
+// endpoints used by the Helm registry client
+type testRegistry struct {
+	mutex     sync.Mutex
+	blobs     map[string][]byte
+	uploads   map[string][]byte
+	manifests map[string]map[string]string
+	//manifestPushes counts manifests pushed to each repository
+	manifestPushes map[string]int
+	contentTypes   map[string]string
+}
+
+func newTestRegistry() *testRegistry {
+	return &testRegistry{
+		blobs:          make(map[string][]byte),
+		uploads:        make(map[string][]byte),
+		manifests:      make(map[string]map[string]string),
+		manifestPushes: make(map[string]int),
+		contentTypes:   make(map[string]string),
+	}
+}
+
+func digestOf(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+func registryError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"errors":[{"code":"%s","message":"%s"}]}`, code, strings.ToLower(strings.ReplaceAll(code, "_", " ")))
+}
+
+func (testRegistry *testRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	testRegistry.mutex.Lock()
+	defer testRegistry.mutex.Unlock()
+
+	if r.URL.Path == "/v2/" || r.URL.Path == "/v2" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	match := registryPath.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		registryError(w, http.StatusNotFound, "UNSUPPORTED")
+		return
+	}
+	name, endpoint := match[1], match[2]
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case strings.HasPrefix(endpoint, "blobs/uploads/"):
+		uploadID := strings.TrimPrefix(endpoint, "blobs/uploads/")
+		switch r.Method {
+		case http.MethodPost:
+			uploadID = fmt.Sprintf("upload-%d", len(testRegistry.uploads)+1)
+			testRegistry.uploads[uploadID] = nil
+		case http.MethodPatch:
+			testRegistry.uploads[uploadID] = append(testRegistry.uploads[uploadID], body...)
+		case http.MethodPut:
+			data := append(testRegistry.uploads[uploadID], body...)
+			delete(testRegistry.uploads, uploadID)
+			digest := r.URL.Query().Get("digest")
+			if digest != digestOf(data) {
+				registryError(w, http.StatusBadRequest, "DIGEST_INVALID")
+				return
+			}
+			testRegistry.blobs[digest] = data
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, digest))
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, uploadID))
+		w.Header().Set("Docker-Upload-UUID", uploadID)
+		w.Header().Set("Range", "0-0")
+		w.WriteHeader(http.StatusAccepted)
+
+	case strings.HasPrefix(endpoint, "blobs/"):
+		digest := strings.TrimPrefix(endpoint, "blobs/")
+		data, ok := testRegistry.blobs[digest]
+		if !ok {
+			registryError(w, http.StatusNotFound, "BLOB_UNKNOWN")
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case strings.HasPrefix(endpoint, "manifests/"):
+		reference := strings.TrimPrefix(endpoint, "manifests/")
+		if r.Method == http.MethodPut {
+			digest := digestOf(body)
+			testRegistry.blobs[digest] = body
+			testRegistry.contentTypes[digest] = r.Header.Get("Content-Type")
+			if testRegistry.manifests[name] == nil {
+				testRegistry.manifests[name] = make(map[string]string)
+			}
+			testRegistry.manifests[name][reference] = digest
+			testRegistry.manifests[name][digest] = digest
+			testRegistry.manifestPushes[name]++
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", name, digest))
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		digest, ok := testRegistry.manifests[name][reference]
+		if !ok {
+			registryError(w, http.StatusNotFound, "MANIFEST_UNKNOWN")
+			return
+		}
+		data := testRegistry.blobs[digest]
+		w.Header().Set("Content-Type", testRegistry.contentTypes[digest])
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case endpoint == "tags/list":
+		references, ok := testRegistry.manifests[name]
+		if !ok {
+			registryError(w, http.StatusNotFound, "NAME_UNKNOWN")
+			return
+		}
+		tags := make([]string, 0)
+		for reference := range references {
+			if !strings.HasPrefix(reference, "sha256:") {
+				tags = append(tags, reference)
+			}
+		}
+		sort.Strings(tags)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "tags": tags})
+	}
+}
+
+// Returns the annotations of the manifest of a tag
+func (testRegistry *testRegistry) manifestAnnotations(t *testing.T, name, tag string) map[string]string {
+	testRegistry.mutex.Lock()
+	defer testRegistry.mutex.Unlock()
+
+	digest, ok := testRegistry.manifests[name][tag]
+	if !ok {
+		t.Fatalf("%s:%s not found in registry", name, tag)
+	}
+	manifest := struct {
+		Annotations map[string]string `json:"annotations"`
+	}{}
+	if err := json.Unmarshal(testRegistry.blobs[digest], &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	return manifest.Annotations
+}
+
+// Saves a chart asset with the given version and annotations
+func saveTestChart(t *testing.T, version string, annotations map[string]string) string {
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:  chart.APIVersionV2,
+			Name:        "foo",
+			Version:     version,
+			Annotations: annotations,
+		},
+	}
+	assetPath, err := chartutil.Save(helmChart, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return assetPath
+}
+
+func chartVersionStrings(chartVersions repo.ChartVersions) []string {
+	versions := make([]string, 0, len(chartVersions))
+	for _, chartVersion := range chartVersions {
+		versions = append(versions, chartVersion.Version)
+	}
+
+	return versions
+}
+
+func TestPublisher(t *testing.T) {
+	testRegistry := newTestRegistry()
+	server := httptest.NewServer(testRegistry)
+	defer server.Close()
+
+	repository := fmt.Sprintf("oci://%s/charts", strings.TrimPrefix(server.URL, "http://"))
+	publisher, err := NewPublisher(repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	annotations := map[string]string{
+		"catalog.cattle.io/display-name": "Foo",
+		"catalog.cattle.io/release-name": "foo",
+	}
+	assets := map[string]string{
+		"1.0.0":  saveTestChart(t, "1.0.0", annotations),
+		"v1.2.3": saveTestChart(t, "v1.2.3", annotations),
+		"1.1":    saveTestChart(t, "1.1", annotations),
+	}
+	chartVersions := repo.ChartVersions{
+		{Metadata: &chart.Metadata{Name: "foo", Version: "1.0.0"}},
+		{Metadata: &chart.Metadata{Name: "foo", Version: "v1.2.3"}},
+		{Metadata: &chart.Metadata{Name: "foo", Version: "1.1"}},
+	}
+
+	missing, err := publisher.MissingVersions("foo", chartVersions)
+	if err != nil {
+		t.Fatalf("unable to list versions of unknown chart: %s", err)
+	}
+	if len(missing) != len(chartVersions) {
+		t.Fatalf("expected all versions to be missing, got %v", chartVersionStrings(missing))
+	}
+
+	if _, err := publisher.Push(assets["1.0.0"]); err != nil {
+		t.Fatal(err)
+	}
+	missing, err = publisher.MissingVersions("foo", chartVersions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if versions := strings.Join(chartVersionStrings(missing), ","); versions != "v1.2.3,1.1" {
+		t.Fatalf("expected v1.2.3,1.1 to be missing, got %s", versions)
+	}
+
+	for _, chartVersion := range missing {
+		if _, err := publisher.Push(assets[chartVersion.Version]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing, err = publisher.MissingVersions("foo", chartVersions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(missing) != 0 {
+		t.Errorf("expected no missing versions, got %v", chartVersionStrings(missing))
+	}
+	if pushes := testRegistry.manifestPushes["charts/foo"]; pushes != len(chartVersions) {
+		t.Errorf("expected %d pushes, got %d", len(chartVersions), pushes)
+	}
+
+	for _, tag := range []string{"1.0.0", "1.2.3", "1.1.0"} {
+		manifestAnnotations := testRegistry.manifestAnnotations(t, "charts/foo", tag)
+		for key, value := range annotations {
+			if manifestAnnotations[key] != value {
+				t.Errorf("%s: expected manifest annotation %s=%s, got '%s'", tag, key, value, manifestAnnotations[key])
+			}
+		}
+
+		pulled, err := publisher.client.Pull(fmt.Sprintf("%s:%s", publisher.chartReference("foo"), tag))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range annotations {
+			if pulled.Chart.Meta.Annotations[key] != value {
+				t.Errorf("%s: expected chart annotation %s=%s, got '%s'", tag, key, value, pulled.Chart.Meta.Annotations[key])
+			}
+		}
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := map[string]string{
+		"1.2.3":         "1.2.3",
+		"v1.2.3":        "1.2.3",
+		"1.0":           "1.0.0",
+		"1.2.3+build.1": "1.2.3+build.1",
+		"latest":        "latest",
+	}
+	for version, expected := range tests {
+		if normalized := normalizeVersion(version); normalized != expected {
+			t.Errorf("%s: expected %s, got %s", version, expected, normalized)
+		}
+	}
+}
+
+type recordingTransport struct {
+	requested []string
+}
+
+func (transport *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport.requested = append(transport.requested, request.URL.String())
+
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: request}, nil
+}
+
+func TestPlainHTTPTransport(t *testing.T) {
+	tests := map[string]string{
+		"https://localhost:5000/v2/":       "http://localhost:5000/v2/",
+		"https://127.0.0.1:5000/v2/":       "http://127.0.0.1:5000/v2/",
+		"https://[::1]:5000/v2/":           "http://[::1]:5000/v2/",
+		"https://registry.example.com/v2/": "https://registry.example.com/v2/",
+		"https://10.0.0.1:5000/v2/":        "https://10.0.0.1:5000/v2/",
+	}
+	for requestURL, expected := range tests {
+		recorder := &recordingTransport{}
+		request, err := http.NewRequest(http.MethodGet, requestURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := (plainHTTPTransport{base: recorder}).RoundTrip(request); err != nil {
+			t.Fatal(err)
+		}
+		if recorder.requested[0] != expected {
+			t.Errorf("%s: expected request to %s, got %s", requestURL, expected, recorder.requested[0])
+		}
+	}
+}
+
+func TestNewPublisherInvalidRepository(t *testing.T) {
+	for _, repository := range []string{"", "oci://", "https://registry.example.com/charts"} {
+		if _, err := NewPublisher(repository); err == nil {
+			t.Errorf("expected '%s' to be rejected", repository)
+		}
+	}
+}
@@ -27,27 +27,58 @@ type PackageYaml struct {
 	Version        string `json:"version,omitempty"`
 }
 
+// ScanSuppression ignores security scan findings of a rule
+type ScanSuppression struct {
+	//Rule is the name of the scan rule
+	Rule string `json:"Rule"`
+	//Source is a glob pattern of chart files the suppression applies to. Applies to all files if empty
+	Source string `json:"Source"`
+	//Reason documents why the finding is accepted
+	Reason string `json:"Reason"`
+}
+
+// Retention limits the chart versions kept in the repository. A version is
+// removed if any rule drops it
+type Retention struct {
+	//KeepPerMinor is the number of newest versions kept for each Major.Minor version. All versions are kept if 0
+	KeepPerMinor int `json:"KeepPerMinor"`
+	//Before drops versions created before the given date, formatted as YYYY-MM-DD
+	Before string `json:"Before"`
+}
+
+// Returns true if no retention rules are set
+func (retention Retention) IsEmpty() bool {
+	return retention.KeepPerMinor == 0 && retention.Before == ""
+}
+
 type UpstreamYaml struct {
-	AHPackageName      string         `json:"ArtifactHubPackage"`
-	AHRepoName         string         `json:"ArtifactHubRepo"`
-	AutoInstall        string         `json:"AutoInstall"`
-	ChartYaml          chart.Metadata `json:"ChartMetadata"`
-	DisplayName        string         `json:"DisplayName"`
-	Experimental       bool           `json:"Experimental"`
-	Fetch              string         `json:"Fetch"`
-	GitBranch          string         `json:"GitBranch"`
-	GitHubRelease      bool           `json:"GitHubRelease"`
-	GitRepoUrl         string         `json:"GitRepo"`
-	GitSubDirectory    string         `json:"GitSubdirectory"`
-	HelmChart          string         `json:"HelmChart"`
-	HelmRepoUrl        string         `json:"HelmRepo"`
-	Hidden             bool           `json:"Hidden"`
-	Namespace          string         `json:"Namespace"`
-	PackageVersion     int            `json:"PackageVersion"`
-	RemoteDependencies bool           `json:"RemoteDependencies"`
-	TrackVersions      []string       `json:"TrackVersions"`
-	ReleaseName        string         `json:"ReleaseName"`
-	Vendor             string         `json:"Vendor"`
+	AHPackageName        string            `json:"ArtifactHubPackage"`
+	AHRepoName           string            `json:"ArtifactHubRepo"`
+	AppReadmeLength      int               `json:"AppReadmeLength"`
+	AutoInstall          string            `json:"AutoInstall"`
+	ChartYaml            chart.Metadata    `json:"ChartMetadata"`
+	DisplayName          string            `json:"DisplayName"`
+	Experimental         bool              `json:"Experimental"`
+	Fetch                string            `json:"Fetch"`
+	GitBranch            string            `json:"GitBranch"`
+	GitHubRelease        bool              `json:"GitHubRelease"`
+	GitRepoUrl           string            `json:"GitRepo"`
+	GitSubDirectory      string            `json:"GitSubdirectory"`
+	HelmChart            string            `json:"HelmChart"`
+	HelmRepoUrl          string            `json:"HelmRepo"`
+	Hidden               bool              `json:"Hidden"`
+	Namespace            string            `json:"Namespace"`
+	PackageVersion       int               `json:"PackageVersion"`
+	PackageVersionFormat string            `json:"PackageVersionFormat"`
+	RancherVersion       string            `json:"RancherVersion"`
+	RemoteDependencies   bool              `json:"RemoteDependencies"`
+	Retention            Retention         `json:"Retention"`
+	ScanSuppressions     []ScanSuppression `json:"ScanSuppressions"`
+	SplitCRDs            bool              `json:"SplitCRDs"`
+	TrackVersions        []string          `json:"TrackVersions"`
+	UpgradeAPIVersion    bool              `json:"UpgradeAPIVersion"`
+	ReleaseName          string            `json:"ReleaseName"`
+	Vendor               string            `json:"Vendor"`
 }
 
 func (packageYaml PackageYaml) Write(overWrite bool) error {
@@ -0,0 +1,73 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+)
+
+var htmlTemplate = template.Must(template.New(HTMLFile).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Partner Chart Catalog</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+nav ul { columns: 3; }
+section { border-top: 1px solid #ccc; padding-top: 1em; margin-top: 2em; }
+table { border-collapse: collapse; margin: 1em 0; }
+th, td { border: 1px solid #ccc; padding: 0.25em 0.75em; text-align: left; }
+th { background: #f4f4f4; }
+dl { display: grid; grid-template-columns: max-content auto; gap: 0.25em 1em; }
+dt { font-weight: bold; }
+dd { margin: 0; }
+pre { white-space: pre-wrap; background: #f8f8f8; padding: 1em; }
+.tag { display: inline-block; border-radius: 3px; padding: 0 0.4em; font-size: 0.8em; background: #ddd; }
+</style>
+</head>
+<body>
+<h1>Partner Chart Catalog</h1>
+<p>Charts: {{ len .Charts }}</p>
+<nav>
+<ul>
+{{- range .Charts }}
+<li><a href="#{{ .Vendor }}-{{ .Name }}">{{ .DisplayName }}</a> ({{ .Vendor }})</li>
+{{- end }}
+</ul>
+</nav>
+{{- range .Charts }}
+<section id="{{ .Vendor }}-{{ .Name }}">
+<h2>{{ .DisplayName }}{{ if .Featured }} <span class="tag">featured #{{ .Featured }}</span>{{ end }}{{ if .Hidden }} <span class="tag">hidden</span>{{ end }}</h2>
+<dl>
+<dt>Vendor</dt><dd>{{ .Vendor }}</dd>
+<dt>Chart</dt><dd>{{ .Name }}</dd>
+<dt>Latest version</dt><dd>{{ (index .Versions 0).Version }}</dd>
+<dt>Last updated</dt><dd>{{ .LastUpdated }}</dd>
+{{- with .Upstream }}
+<dt>Upstream</dt><dd>{{ .Type }}: <a href="{{ .URL }}">{{ .URL }}</a>{{ with .Chart }} ({{ . }}){{ end }}{{ with .Branch }} branch {{ . }}{{ end }}{{ with .Subdirectory }} in {{ . }}{{ end }}</dd>
+{{- end }}
+</dl>
+<table>
+<tr><th>Version</th><th>App version</th><th>Kubernetes</th><th>Created</th><th>State</th></tr>
+{{- range .Versions }}
+<tr><td>{{ .Version }}</td><td>{{ .AppVersion }}</td><td>{{ .KubeVersion }}</td><td>{{ .Created }}</td><td>{{ if .Deprecated }}deprecated {{ end }}{{ if .Hidden }}hidden{{ end }}</td></tr>
+{{- end }}
+</table>
+{{- with .AppReadme }}
+<pre>{{ . }}</pre>
+{{- end }}
+</section>
+{{- end }}
+</body>
+</html>
+`))
+
+// Formats the report as a static HTML page
+func (report Report) HTML() ([]byte, error) {
+	var out bytes.Buffer
+	err := htmlTemplate.Execute(&out, report)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
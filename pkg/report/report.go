@@ -0,0 +1,247 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samuelattwood/partner-charts-ci/pkg/parse"
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+const (
+	//HTMLFile is the name of the HTML catalog written to the report directory
+	HTMLFile = "index.html"
+	//JSONFile is the name of the JSON catalog written to the report directory
+	JSONFile = "catalog.json"
+	//DateFormat is the layout of dates in the report
+	DateFormat = "2006-01-02"
+
+	//SourceArtifactHub is a chart fetched through Artifact Hub
+	SourceArtifactHub = "artifacthub"
+	//SourceHelmRepo is a chart fetched from a Helm repository
+	SourceHelmRepo = "helm"
+	//SourceGit is a chart fetched from a git repository
+	SourceGit = "git"
+
+	annotationDisplayName = "catalog.cattle.io/display-name"
+	annotationFeatured    = "catalog.cattle.io/featured"
+	annotationHidden      = "catalog.cattle.io/hidden"
+	annotationKubeVersion = "catalog.cattle.io/kube-version"
+	appReadmeFile         = "app-readme.md"
+	artifactHubPackageURL = "https://artifacthub.io/packages/helm"
+)
+
+// Source is the upstream a chart is fetched from, as set in upstream.yaml
+type Source struct {
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+	Chart string `json:"chart,omitempty"`
+	//Branch and Subdirectory are set for git sources
+	Branch       string `json:"branch,omitempty"`
+	Subdirectory string `json:"subdirectory,omitempty"`
+}
+
+// Version is a released version of a chart
+type Version struct {
+	Version    string `json:"version"`
+	AppVersion string `json:"appVersion,omitempty"`
+	//KubeVersion is the range of Kubernetes versions the chart supports
+	KubeVersion string `json:"kubeVersion,omitempty"`
+	Created     string `json:"created"`
+	Deprecated  bool   `json:"deprecated,omitempty"`
+	Hidden      bool   `json:"hidden,omitempty"`
+}
+
+// Chart is a chart in the catalog with its released versions, newest first.
+// Display name, featured, and hidden state are those of the latest version
+type Chart struct {
+	Vendor      string `json:"vendor"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	//Featured is the featured rank of the chart, or 0 if not featured
+	Featured    int       `json:"featured,omitempty"`
+	Hidden      bool      `json:"hidden,omitempty"`
+	Upstream    *Source   `json:"upstream,omitempty"`
+	LastUpdated string    `json:"lastUpdated"`
+	AppReadme   string    `json:"appReadme,omitempty"`
+	Versions    []Version `json:"versions"`
+}
+
+// Report is a catalog of the charts in the repository
+type Report struct {
+	Charts []Chart `json:"charts"`
+}
+
+// Returns the upstream source configured in upstream.yaml, in the order
+// upstreams are fetched. Returns nil if no upstream is configured
+func UpstreamSource(upstreamYaml parse.UpstreamYaml) *Source {
+	if upstreamYaml.AHRepoName != "" && upstreamYaml.AHPackageName != "" {
+		return &Source{
+			Type:  SourceArtifactHub,
+			URL:   fmt.Sprintf("%s/%s/%s", artifactHubPackageURL, upstreamYaml.AHRepoName, upstreamYaml.AHPackageName),
+			Chart: upstreamYaml.AHPackageName,
+		}
+	} else if upstreamYaml.HelmRepoUrl != "" && upstreamYaml.HelmChart != "" {
+		return &Source{
+			Type:  SourceHelmRepo,
+			URL:   upstreamYaml.HelmRepoUrl,
+			Chart: upstreamYaml.HelmChart,
+		}
+	} else if upstreamYaml.GitRepoUrl != "" {
+		return &Source{
+			Type:         SourceGit,
+			URL:          upstreamYaml.GitRepoUrl,
+			Branch:       upstreamYaml.GitBranch,
+			Subdirectory: upstreamYaml.GitSubDirectory,
+		}
+	}
+
+	return nil
+}
+
+// Returns the vendor directory of a chart version's asset under assetsURL
+func versionVendor(chartVersion *repo.ChartVersion, assetsURL string) string {
+	if len(chartVersion.URLs) == 0 {
+		return ""
+	}
+	assetPath := strings.Split(strings.TrimPrefix(chartVersion.URLs[0], assetsURL+"/"), "/")
+	if len(assetPath) != 2 {
+		return ""
+	}
+
+	return assetPath[0]
+}
+
+// Generates the catalog from the index and the app-readme of each chart in
+// chartsPath. Asset URLs are expected under assetsURL and sources are keyed by
+// <vendor>/<chart>. Charts are sorted by vendor and name so that the same
+// inputs always generate the same report
+func Generate(indexFile *repo.IndexFile, assetsURL, chartsPath string, sources map[string]*Source) (Report, error) {
+	indexFile.SortEntries()
+	report := Report{Charts: make([]Chart, 0, len(indexFile.Entries))}
+	for chartName, chartVersions := range indexFile.Entries {
+		if len(chartVersions) == 0 {
+			continue
+		}
+		latest := chartVersions[0]
+		vendor := versionVendor(latest, assetsURL)
+
+		catalogChart := Chart{
+			Vendor:      vendor,
+			Name:        chartName,
+			DisplayName: chartName,
+			Hidden:      latest.Annotations[annotationHidden] == "true",
+			Upstream:    sources[path.Join(vendor, chartName)],
+			Versions:    make([]Version, 0, len(chartVersions)),
+		}
+		if displayName, ok := latest.Annotations[annotationDisplayName]; ok && displayName != "" {
+			catalogChart.DisplayName = displayName
+		}
+		if featured, ok := latest.Annotations[annotationFeatured]; ok {
+			rank, err := strconv.Atoi(featured)
+			if err != nil {
+				logrus.Warnf("%s has invalid featured annotation '%s'\n", chartName, featured)
+			}
+			catalogChart.Featured = rank
+		}
+
+		var lastUpdated time.Time
+		for _, chartVersion := range chartVersions {
+			kubeVersion := chartVersion.KubeVersion
+			if annotatedVersion, ok := chartVersion.Annotations[annotationKubeVersion]; ok {
+				kubeVersion = annotatedVersion
+			}
+			catalogChart.Versions = append(catalogChart.Versions, Version{
+				Version:     chartVersion.Version,
+				AppVersion:  chartVersion.AppVersion,
+				KubeVersion: kubeVersion,
+				Created:     chartVersion.Created.UTC().Format(DateFormat),
+				Deprecated:  chartVersion.Deprecated,
+				Hidden:      chartVersion.Annotations[annotationHidden] == "true",
+			})
+			if chartVersion.Created.After(lastUpdated) {
+				lastUpdated = chartVersion.Created
+			}
+		}
+		catalogChart.LastUpdated = lastUpdated.UTC().Format(DateFormat)
+
+		if vendor != "" {
+			appReadme, err := os.ReadFile(filepath.Join(chartsPath, vendor, chartName, appReadmeFile))
+			if err != nil && !os.IsNotExist(err) {
+				return Report{}, err
+			}
+			catalogChart.AppReadme = strings.TrimSpace(string(appReadme))
+		}
+
+		report.Charts = append(report.Charts, catalogChart)
+	}
+
+	sort.Slice(report.Charts, func(i, j int) bool {
+		if report.Charts[i].Vendor != report.Charts[j].Vendor {
+			return report.Charts[i].Vendor < report.Charts[j].Vendor
+		}
+		return report.Charts[i].Name < report.Charts[j].Name
+	})
+
+	return report, nil
+}
+
+// Formats the report as indented JSON. App-readme content is not HTML escaped
+func (report Report) JSON() ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(report)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// Writes the HTML and JSON catalogs to outputPath. Files are only written if
+// their contents change
+func (report Report) Write(outputPath string) error {
+	err := os.MkdirAll(outputPath, 0755)
+	if err != nil {
+		return err
+	}
+
+	jsonData, err := report.JSON()
+	if err != nil {
+		return err
+	}
+	htmlData, err := report.HTML()
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		filepath.Join(outputPath, JSONFile): jsonData,
+		filepath.Join(outputPath, HTMLFile): htmlData,
+	}
+	for filePath, data := range files {
+		if current, err := os.ReadFile(filePath); err == nil && bytes.Equal(current, data) {
+			logrus.Debugf("%s is unchanged\n", filePath)
+			continue
+		}
+		err = os.WriteFile(filePath, data, 0644)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Wrote %s\n", filePath)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"bytes"
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// Returns the sources of the charts in testdata/index.yaml
+func testSources() map[string]*Source {
+	return map[string]*Source{
+		"acme/foo": {Type: SourceHelmRepo, URL: "https://charts.example.com", Chart: "foo"},
+		"globex/bar": {
+			Type:         SourceGit,
+			URL:          "https://github.com/example/bar",
+			Branch:       "main",
+			Subdirectory: "charts/bar",
+		},
+	}
+}
+
+// Generates the report of testdata/index.yaml. Versions of each chart are
+// shuffled with the given seed, as the order of an index on disk is not
+// guaranteed
+func generateTestReport(t *testing.T, seed int64) Report {
+	t.Helper()
+	indexFile, err := repo.LoadIndexFile(filepath.Join("testdata", "index.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shuffle := rand.New(rand.NewSource(seed))
+	for _, chartVersions := range indexFile.Entries {
+		shuffle.Shuffle(len(chartVersions), func(i, j int) {
+			chartVersions[i], chartVersions[j] = chartVersions[j], chartVersions[i]
+		})
+	}
+
+	report, err := Generate(indexFile, "assets", filepath.Join("testdata", "charts"), testSources())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return report
+}
+
+func TestGenerateGolden(t *testing.T) {
+	report := generateTestReport(t, 1)
+	jsonData, err := report.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	htmlData, err := report.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{JSONFile: jsonData, HTMLFile: htmlData} {
+		goldenPath := filepath.Join("testdata", name+".golden")
+		if *update {
+			if err = os.WriteFile(goldenPath, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		golden, err := os.ReadFile(goldenPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, golden) {
+			t.Errorf("%s does not match %s. Run with -update if the change is intended\n%s", name, goldenPath, data)
+		}
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	outputPath := t.TempDir()
+	if err := generateTestReport(t, 1).Write(outputPath); err != nil {
+		t.Fatal(err)
+	}
+
+	written := make(map[string][]byte)
+	modTimes := make(map[string]time.Time)
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, name := range []string{JSONFile, HTMLFile} {
+		filePath := filepath.Join(outputPath, name)
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written[name] = data
+		if err = os.Chtimes(filePath, past, past); err != nil {
+			t.Fatal(err)
+		}
+		modTimes[name] = past
+	}
+
+	for seed := int64(2); seed < 10; seed++ {
+		if err := generateTestReport(t, seed).Write(outputPath); err != nil {
+			t.Fatal(err)
+		}
+		for name, data := range written {
+			filePath := filepath.Join(outputPath, name)
+			current, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(current, data) {
+				t.Fatalf("%s differs for version order seed %d", name, seed)
+			}
+			info, err := os.Stat(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.ModTime().Equal(modTimes[name]) {
+				t.Errorf("expected unchanged %s not to be rewritten", name)
+			}
+		}
+	}
+}
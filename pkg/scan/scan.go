@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
+	"github.com/samuelattwood/partner-charts-ci/pkg/parse"
+	"github.com/sirupsen/logrus"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+var severityRank = map[string]int{
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// Finding is an issue found in chart content
+type Finding struct {
+	//Rule identifies the check that produced the finding
+	Rule     string
+	Severity string
+	//Source is the chart file the finding was found in
+	Source  string
+	Message string
+}
+
+func (finding Finding) String() string {
+	return fmt.Sprintf("[%s] %s: %s (%s)", finding.Severity, finding.Source, finding.Message, finding.Rule)
+}
+
+// Returns true if the finding is at or above the given severity
+func (finding Finding) AtLeast(severity string) bool {
+	rank, ok := severityRank[severity]
+	return ok && severityRank[finding.Severity] >= rank
+}
+
+// Returns true if the severity is known
+func ValidSeverity(severity string) bool {
+	_, ok := severityRank[severity]
+	return ok
+}
+
+// Returns true if the finding matches a suppression. The suppression source
+// may be a glob pattern and matches all files if empty
+func suppressed(finding Finding, suppressions []parse.ScanSuppression) bool {
+	for _, suppression := range suppressions {
+		if suppression.Rule != finding.Rule {
+			continue
+		}
+		if suppression.Source == "" {
+			return true
+		}
+		if match, _ := path.Match(suppression.Source, finding.Source); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Scans chart files for secrets and rendered workloads for risky defaults.
+// Findings matching a suppression are omitted
+func Chart(helmChart *chart.Chart, namespace string, suppressions []parse.ScanSuppression) []Finding {
+	findings := scanSecrets(helmChart, "")
+
+	rendered, err := conform.RenderChart(helmChart, namespace, "")
+	if err != nil {
+		logrus.Debugf("Unable to render %s (%s) to scan workloads: %s\n", helmChart.Name(), helmChart.Metadata.Version, err)
+	} else {
+		findings = append(findings, scanWorkloads(helmChart.Name(), conform.ParseManifests(helmChart, rendered))...)
+	}
+
+	unique := make(map[string]bool)
+	filtered := make([]Finding, 0, len(findings))
+	for _, finding := range findings {
+		if suppressed(finding, suppressions) {
+			logrus.Debugf("Suppressed %s\n", finding)
+			continue
+		}
+		if unique[finding.String()] {
+			continue
+		}
+		unique[finding.String()] = true
+		filtered = append(filtered, finding)
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if filtered[i].Severity != filtered[j].Severity {
+			return severityRank[filtered[i].Severity] > severityRank[filtered[j].Severity]
+		}
+		if filtered[i].Source != filtered[j].Source {
+			return filtered[i].Source < filtered[j].Source
+		}
+		return filtered[i].Message < filtered[j].Message
+	})
+
+	return filtered
+}
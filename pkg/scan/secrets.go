@@ -0,0 +1,211 @@
+package scan
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	//minEntropyLength is the shortest value checked for high entropy
+	minEntropyLength = 20
+	//entropyThreshold is the Shannon entropy in bits per character above which a value looks random
+	entropyThreshold = 4.0
+)
+
+type secretPattern struct {
+	rule     string
+	severity string
+	message  string
+	pattern  *regexp.Regexp
+}
+
+var secretPatterns = []secretPattern{
+	{"private-key", SeverityCritical, "a private key", regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`)},
+	{"aws-access-key", SeverityHigh, "an AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"github-token", SeverityHigh, "a GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"slack-token", SeverityHigh, "a Slack token", regexp.MustCompile(`\bxox[abprs]-[0-9A-Za-z-]{10,}\b`)},
+	{"jwt", SeverityMedium, "a JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\b`)},
+}
+
+var (
+	//secretKeyPattern matches values keys that hold credentials
+	secretKeyPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|access_?key|private_?key|credentials)$`)
+	//randomPattern matches values made of characters used in keys and tokens
+	randomPattern = regexp.MustCompile(`^[A-Za-z0-9+/=_\-.]+$`)
+	//templateLiteralPattern matches template lines setting a key to a literal, optionally quoted, value
+	templateLiteralPattern = regexp.MustCompile(`^\s*(?:-\s+)?([A-Za-z0-9_.\-]+):\s+["']?([^"'\s#{}]+)["']?\s*$`)
+)
+
+// Returns the Shannon entropy of a string in bits per character
+func shannonEntropy(value string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range value {
+		counts[r]++
+	}
+
+	entropy := 0.0
+	length := float64(len([]rune(value)))
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// Returns true if a values entry is a placeholder rather than a credential
+func isPlaceholder(value string) bool {
+	trimmed := strings.TrimSpace(value)
+	return trimmed == "" || strings.Contains(trimmed, "{{") || strings.HasPrefix(trimmed, "$")
+}
+
+// Returns true if a literal is a YAML boolean, number, or null
+func isScalar(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	_, err := strconv.ParseFloat(value, 64)
+
+	return err == nil
+}
+
+// Checks a literal value set for a key. Returns a finding if the key holds a
+// credential or the value looks like a generated secret, or nil otherwise
+func checkLiteral(key, value, location, source string) *Finding {
+	if secretKeyPattern.MatchString(key) {
+		return &Finding{
+			Rule:     "default-credential",
+			Severity: SeverityMedium,
+			Source:   source,
+			Message:  fmt.Sprintf("%s has a default value", location),
+		}
+	}
+	if len(value) >= minEntropyLength && randomPattern.MatchString(value) && shannonEntropy(value) > entropyThreshold {
+		return &Finding{
+			Rule:     "high-entropy",
+			Severity: SeverityLow,
+			Source:   source,
+			Message:  fmt.Sprintf("%s looks like a generated secret", location),
+		}
+	}
+
+	return nil
+}
+
+// Reports credentials and random looking strings set as literal values in a
+// template. Values set by template actions are not checked
+func scanTemplate(data []byte, source string) []Finding {
+	findings := make([]Finding, 0)
+	for i, line := range strings.Split(string(data), "\n") {
+		match := templateLiteralPattern.FindStringSubmatch(line)
+		if match == nil || isPlaceholder(match[2]) || isScalar(match[2]) {
+			continue
+		}
+		location := fmt.Sprintf("%s on line %d", match[1], i+1)
+		if finding := checkLiteral(match[1], match[2], location, source); finding != nil {
+			findings = append(findings, *finding)
+		}
+	}
+
+	return findings
+}
+
+// Walks chart values and reports credentials set by default and random
+// looking strings
+func scanValues(values map[string]interface{}, keyPath, source string) []Finding {
+	findings := make([]Finding, 0)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		currentPath := key
+		if keyPath != "" {
+			currentPath = keyPath + "." + key
+		}
+		switch value := values[key].(type) {
+		case map[string]interface{}:
+			findings = append(findings, scanValues(value, currentPath, source)...)
+		case []interface{}:
+			for i, item := range value {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					findings = append(findings, scanValues(itemMap, fmt.Sprintf("%s[%d]", currentPath, i), source)...)
+				}
+			}
+		case string:
+			if isPlaceholder(value) {
+				continue
+			}
+			if finding := checkLiteral(key, value, currentPath, source); finding != nil {
+				findings = append(findings, *finding)
+			}
+		}
+	}
+
+	return findings
+}
+
+// Returns the files of a chart as loaded. Charts built in memory, such as
+// split CRD charts, have no loaded files, so their templates, files and
+// values are returned instead
+func chartFiles(helmChart *chart.Chart) []*chart.File {
+	if len(helmChart.Raw) > 0 {
+		return helmChart.Raw
+	}
+
+	files := make([]*chart.File, 0, len(helmChart.Templates)+len(helmChart.Files)+1)
+	files = append(files, helmChart.Templates...)
+	files = append(files, helmChart.Files...)
+	if len(helmChart.Values) > 0 {
+		values, err := yaml.Marshal(helmChart.Values)
+		if err == nil {
+			files = append(files, &chart.File{Name: chartutil.ValuesfileName, Data: values})
+		}
+	}
+
+	return files
+}
+
+// Scans chart and subchart files and templates for secrets. Subchart files
+// are reported with their path in the parent chart
+func scanSecrets(helmChart *chart.Chart, prefix string) []Finding {
+	findings := make([]Finding, 0)
+	for _, f := range chartFiles(helmChart) {
+		source := path.Join(prefix, f.Name)
+		for _, secret := range secretPatterns {
+			if secret.pattern.Match(f.Data) {
+				findings = append(findings, Finding{
+					Rule:     secret.rule,
+					Severity: secret.severity,
+					Source:   source,
+					Message:  "contains " + secret.message,
+				})
+			}
+		}
+	}
+
+	for _, template := range helmChart.Templates {
+		findings = append(findings, scanTemplate(template.Data, path.Join(prefix, template.Name))...)
+	}
+
+	findings = append(findings, scanValues(helmChart.Values, "", path.Join(prefix, chartutil.ValuesfileName))...)
+
+	for _, dependency := range helmChart.Dependencies() {
+		findings = append(findings, scanSecrets(dependency, path.Join(prefix, chartutil.ChartsDir, dependency.Name()))...)
+	}
+
+	return findings
+}
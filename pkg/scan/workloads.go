@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
+)
+
+var hostNamespaceRules = map[string]string{
+	"hostNetwork": "host-network",
+	"hostPID":     "host-pid",
+	"hostIPC":     "host-ipc",
+}
+
+func boolField(object map[string]interface{}, field string) bool {
+	value, _ := object[field].(bool)
+	return value
+}
+
+// Returns true if the security context runs as the root user
+func runsAsRoot(securityContext map[string]interface{}) bool {
+	runAsUser, ok := securityContext["runAsUser"].(float64)
+	return ok && runAsUser == 0
+}
+
+// Reports privileged containers, host namespaces, host path volumes, and
+// containers running as root in rendered workloads. Sources are reported
+// relative to the chart directory
+func scanWorkloads(chartName string, manifests []conform.Manifest) []Finding {
+	findings := make([]Finding, 0)
+	for _, manifest := range manifests {
+		spec, _ := conform.PodSpec(manifest)
+		if spec == nil {
+			continue
+		}
+		resource := fmt.Sprintf("%s %s", manifest.Kind(), manifest.Name())
+		finding := func(rule, severity, format string, args ...interface{}) {
+			findings = append(findings, Finding{
+				Rule:     rule,
+				Severity: severity,
+				Source:   strings.TrimPrefix(manifest.Source, chartName+"/"),
+				Message:  resource + " " + fmt.Sprintf(format, args...),
+			})
+		}
+
+		for _, namespace := range []string{"hostNetwork", "hostPID", "hostIPC"} {
+			if boolField(spec, namespace) {
+				finding(hostNamespaceRules[namespace], SeverityMedium, "sets %s", namespace)
+			}
+		}
+
+		if volumes, ok := spec["volumes"].([]interface{}); ok {
+			for _, v := range volumes {
+				volume, _ := v.(map[string]interface{})
+				if hostPath, ok := volume["hostPath"].(map[string]interface{}); ok {
+					finding("host-path", SeverityMedium, "mounts host path %v", hostPath["path"])
+				}
+			}
+		}
+
+		podSecurityContext, _ := spec["securityContext"].(map[string]interface{})
+		podRunsAsRoot := runsAsRoot(podSecurityContext)
+		if podRunsAsRoot {
+			finding("run-as-root", SeverityMedium, "runs as user 0")
+		}
+
+		for _, containerField := range []string{"initContainers", "containers", "ephemeralContainers"} {
+			containers, _ := spec[containerField].([]interface{})
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				securityContext, _ := container["securityContext"].(map[string]interface{})
+				if boolField(securityContext, "privileged") {
+					finding("privileged", SeverityHigh, "container %v is privileged", container["name"])
+				}
+				if !podRunsAsRoot && runsAsRoot(securityContext) {
+					finding("run-as-root", SeverityMedium, "container %v runs as user 0", container["name"])
+				}
+			}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,127 @@
+package sign
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumsFile is the name of the asset checksums file in the assets
+// directory, in the format of sha256sum
+const ChecksumsFile = "SHA256SUMS"
+
+// Returns the SHA-256 checksum of each chart asset in the assets directory and
+// its vendor subdirectories, keyed by path relative to the assets directory
+func assetChecksums(assetsPath string) (map[string]string, error) {
+	archives, err := filepath.Glob(filepath.Join(assetsPath, "*.tgz"))
+	if err != nil {
+		return nil, err
+	}
+	vendorArchives, err := filepath.Glob(filepath.Join(assetsPath, "*", "*.tgz"))
+	if err != nil {
+		return nil, err
+	}
+	archives = append(archives, vendorArchives...)
+
+	checksums := make(map[string]string)
+	for _, archive := range archives {
+		file, err := os.Open(archive)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.New()
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		relativePath, err := filepath.Rel(assetsPath, archive)
+		if err != nil {
+			return nil, err
+		}
+		checksums[filepath.ToSlash(relativePath)] = fmt.Sprintf("%x", hash.Sum(nil))
+	}
+
+	return checksums, nil
+}
+
+// Returns the keys of a map of checksums in sorted order
+func sortedPaths(checksums map[string]string) []string {
+	paths := make([]string, 0, len(checksums))
+	for assetPath := range checksums {
+		paths = append(paths, assetPath)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Generates the checksums file for the chart assets. Assets are listed in
+// sorted order so that unchanged assets generate the same file, which can be
+// checked with 'sha256sum -c' from the assets directory
+func Checksums(assetsPath string) ([]byte, error) {
+	checksums, err := assetChecksums(assetsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	for _, assetPath := range sortedPaths(checksums) {
+		fmt.Fprintf(&out, "%s  %s\n", checksums[assetPath], assetPath)
+	}
+
+	return out.Bytes(), nil
+}
+
+// Compares the checksums file in the assets directory with the chart assets.
+// Returns a description of each asset that is missing, not listed, or does not
+// match its checksum
+func VerifyChecksums(assetsPath string) ([]string, error) {
+	checksumsData, err := os.ReadFile(filepath.Join(assetsPath, ChecksumsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(checksumsData))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line in %s: %s", ChecksumsFile, line)
+		}
+		listed[strings.TrimPrefix(fields[1], "*")] = fields[0]
+	}
+
+	checksums, err := assetChecksums(assetsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := make([]string, 0)
+	for _, assetPath := range sortedPaths(listed) {
+		checksum, ok := checksums[assetPath]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s is listed in %s but missing", assetPath, ChecksumsFile))
+		} else if checksum != listed[assetPath] {
+			mismatches = append(mismatches, fmt.Sprintf("%s does not match its checksum", assetPath))
+		}
+	}
+	for _, assetPath := range sortedPaths(checksums) {
+		if _, ok := listed[assetPath]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s is not listed in %s", assetPath, ChecksumsFile))
+		}
+	}
+
+	return mismatches, nil
+}
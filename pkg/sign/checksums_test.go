@@ -0,0 +1,100 @@
+package sign
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Writes the given assets, keyed by path relative to assetsPath
+func writeTestAssets(t *testing.T, assetsPath string, assets map[string]string) {
+	t.Helper()
+	for name, content := range assets {
+		assetPath := filepath.Join(assetsPath, name)
+		if err := os.MkdirAll(filepath.Dir(assetPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(assetPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	assetsPath := t.TempDir()
+	writeTestAssets(t, assetsPath, map[string]string{
+		"acme/foo-1.0.0.tgz":   "foo",
+		"acme/bar-1.0.0.tgz":   "bar",
+		"globex/baz-1.0.0.tgz": "baz",
+		"top-1.0.0.tgz":        "top",
+		"acme/README.md":       "not an asset",
+	})
+
+	checksums, err := Checksums(assetsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(assetsPath, ChecksumsFile), checksums, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err := VerifyChecksums(assetsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Fatalf("expected generated checksums to verify, got %v", mismatches)
+	}
+
+	if err = os.Remove(filepath.Join(assetsPath, "acme", "bar-1.0.0.tgz")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestAssets(t, assetsPath, map[string]string{
+		"acme/foo-1.0.0.tgz": "modified",
+		"acme/new-1.0.0.tgz": "new",
+	})
+
+	mismatches, err = VerifyChecksums(assetsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"acme/bar-1.0.0.tgz is listed in SHA256SUMS but missing",
+		"acme/foo-1.0.0.tgz does not match its checksum",
+		"acme/new-1.0.0.tgz is not listed in SHA256SUMS",
+	}
+	if !reflect.DeepEqual(mismatches, expected) {
+		t.Errorf("expected %v, got %v", expected, mismatches)
+	}
+}
+
+func TestChecksumsFormat(t *testing.T) {
+	assetsPath := t.TempDir()
+	writeTestAssets(t, assetsPath, map[string]string{
+		"acme/foo-1.0.0.tgz": "foo",
+		"top-1.0.0.tgz":      "",
+	})
+
+	checksums, err := Checksums(assetsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae  acme/foo-1.0.0.tgz\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  top-1.0.0.tgz\n"
+	if string(checksums) != expected {
+		t.Errorf("expected sha256sum format\n%s\ngot\n%s", expected, checksums)
+	}
+}
+
+func TestVerifyChecksumsInvalid(t *testing.T) {
+	assetsPath := t.TempDir()
+	if _, err := VerifyChecksums(assetsPath); err == nil {
+		t.Error("expected missing checksums file to fail")
+	}
+
+	writeTestAssets(t, assetsPath, map[string]string{ChecksumsFile: "not a checksum line\n"})
+	if _, err := VerifyChecksums(assetsPath); err == nil {
+		t.Error("expected invalid checksums file to fail")
+	}
+}
@@ -0,0 +1,206 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// Writes an armored OpenPGP block to a file in dir and returns its path
+func writeArmored(t *testing.T, dir, name, blockType string, serialize func(w *bytes.Buffer) error) string {
+	t.Helper()
+	var serialized bytes.Buffer
+	if err := serialize(&serialized); err != nil {
+		t.Fatal(err)
+	}
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, blockType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(serialized.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath := filepath.Join(dir, name)
+	if err = os.WriteFile(keyPath, armored.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return keyPath
+}
+
+// Generates an OpenPGP key, encrypted if a passphrase is given, and returns
+// the paths of its private and public keys
+func generateOpenPGPKey(t *testing.T, passphrase string) (string, string) {
+	t.Helper()
+	config := &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA}
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	publicPath := writeArmored(t, dir, "public.asc", openpgp.PublicKeyType, func(w *bytes.Buffer) error {
+		return entity.Serialize(w)
+	})
+	if passphrase != "" {
+		if err = entity.EncryptPrivateKeys([]byte(passphrase), config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	privatePath := writeArmored(t, dir, "private.asc", openpgp.PrivateKeyType, func(w *bytes.Buffer) error {
+		return entity.SerializePrivateWithoutSigning(w, config)
+	})
+
+	return privatePath, publicPath
+}
+
+// Writes a PEM block to a file in dir and returns its path
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	keyPath := filepath.Join(dir, name)
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return keyPath
+}
+
+// Generates an ed25519 key and returns the paths of its PEM encoded private
+// and public keys
+func generateEd25519Key(t *testing.T) (string, string) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	return writePEM(t, dir, "private.pem", pemPrivateKeyType, privateDER), writePEM(t, dir, "public.pem", pemPublicKeyType, publicDER)
+}
+
+func TestSignVerify(t *testing.T) {
+	openPGPPrivate, openPGPPublic := generateOpenPGPKey(t, "")
+	encryptedPrivate, encryptedPublic := generateOpenPGPKey(t, "secret")
+	ed25519Private, ed25519Public := generateEd25519Key(t)
+	_, otherPublic := generateEd25519Key(t)
+
+	tests := []struct {
+		name       string
+		privateKey string
+		passphrase string
+		publicKey  string
+		otherKey   string
+		extension  string
+	}{
+		{"openpgp", openPGPPrivate, "", openPGPPublic, encryptedPublic, OpenPGPExtension},
+		{"encrypted openpgp", encryptedPrivate, "secret", encryptedPublic, openPGPPublic, OpenPGPExtension},
+		{"ed25519", ed25519Private, "", ed25519Public, otherPublic, Ed25519Extension},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signer, err := LoadSigner(test.privateKey, test.passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signer.Extension() != test.extension {
+				t.Errorf("expected extension %s, got %s", test.extension, signer.Extension())
+			}
+
+			filePath := filepath.Join(t.TempDir(), "index.yaml")
+			data := []byte("apiVersion: v1\nentries: {}\n")
+			if err = os.WriteFile(filePath, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			signature, err := signer.Sign(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = os.WriteFile(filePath+signer.Extension(), signature, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = signer.Verify(data, signature); err != nil {
+				t.Errorf("signer does not verify its own signature: %s", err)
+			}
+			verifier, err := LoadVerifier(test.publicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = VerifyFile(verifier, filePath); err != nil {
+				t.Errorf("expected signature to verify: %s", err)
+			}
+
+			otherVerifier, err := LoadVerifier(test.otherKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if otherVerifier.Extension() == test.extension {
+				if err = VerifyFile(otherVerifier, filePath); err == nil {
+					t.Error("expected signature to fail with another key")
+				}
+			}
+
+			if err = os.WriteFile(filePath, append(data, '#'), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err = VerifyFile(verifier, filePath); err == nil {
+				t.Error("expected signature of modified file to fail")
+			}
+
+			if err = os.Remove(filePath + signer.Extension()); err != nil {
+				t.Fatal(err)
+			}
+			if err = VerifyFile(verifier, filePath); err == nil {
+				t.Error("expected unsigned file to fail")
+			}
+		})
+	}
+}
+
+func TestLoadSignerErrors(t *testing.T) {
+	encryptedPrivate, publicKey := generateOpenPGPKey(t, "secret")
+	_, ed25519Public := generateEd25519Key(t)
+
+	tests := []struct {
+		name       string
+		keyPath    string
+		passphrase string
+	}{
+		{"encrypted without passphrase", encryptedPrivate, ""},
+		{"wrong passphrase", encryptedPrivate, "wrong"},
+		{"openpgp public key", publicKey, ""},
+		{"ed25519 public key", ed25519Public, ""},
+		{"missing key", filepath.Join(t.TempDir(), "missing.pem"), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := LoadSigner(test.keyPath, test.passphrase); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package validate
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// AnnotationPolicy declares constraints on the annotations of every chart
+type AnnotationPolicy struct {
+	//Required annotations must be set with a non-empty value
+	Required []string
+	//Forbidden annotations must not be set. Entries may be glob patterns
+	Forbidden []string
+	//Overridable annotations keep the value set by the chart vendor. If not
+	//set, all vendor set annotations are kept. Entries may be glob patterns
+	Overridable []string
+	//AllowedValues restricts annotations to the listed values
+	AllowedValues map[string][]string
+	//Managed annotations are set by the CI and are never forbidden. Not read
+	//from configuration.yaml
+	Managed []string `json:"-"`
+}
+
+// PolicyViolation lists the annotation policy violations of a chart version
+type PolicyViolation struct {
+	Chart      string
+	Version    string
+	URL        string
+	Violations []string
+}
+
+func matchAnnotation(patterns []string, annotation string) bool {
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, annotation); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if the annotation must not be set. Managed annotations are
+// never forbidden
+func (policy AnnotationPolicy) IsForbidden(annotation string) bool {
+	for _, managed := range policy.Managed {
+		if annotation == managed {
+			return false
+		}
+	}
+
+	return matchAnnotation(policy.Forbidden, annotation)
+}
+
+// Returns true if a value set by the chart vendor takes precedence
+func (policy AnnotationPolicy) IsOverridable(annotation string) bool {
+	if policy.Overridable == nil {
+		return true
+	}
+
+	return matchAnnotation(policy.Overridable, annotation)
+}
+
+// Returns the policy violations of the given annotations in sorted order
+func (policy AnnotationPolicy) Check(annotations map[string]string) []string {
+	violations := make([]string, 0)
+	for _, annotation := range policy.Required {
+		if annotations[annotation] == "" {
+			violations = append(violations, fmt.Sprintf("required annotation %s is not set", annotation))
+		}
+	}
+
+	for annotation, value := range annotations {
+		if policy.IsForbidden(annotation) {
+			violations = append(violations, fmt.Sprintf("forbidden annotation %s is set", annotation))
+			continue
+		}
+		allowedValues, ok := policy.AllowedValues[annotation]
+		if !ok {
+			continue
+		}
+		allowed := false
+		for _, allowedValue := range allowedValues {
+			if value == allowedValue {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("annotation %s has value '%s', expected one of [%s]",
+				annotation, value, strings.Join(allowedValues, ", ")))
+		}
+	}
+	sort.Strings(violations)
+
+	return violations
+}
+
+// Checks every chart version in the index against the policy
+func (policy AnnotationPolicy) CheckIndex(index *repo.IndexFile) []PolicyViolation {
+	chartNames := make([]string, 0, len(index.Entries))
+	for chartName := range index.Entries {
+		chartNames = append(chartNames, chartName)
+	}
+	sort.Strings(chartNames)
+
+	policyViolations := make([]PolicyViolation, 0)
+	for _, chartName := range chartNames {
+		for _, chartVersion := range index.Entries[chartName] {
+			violations := policy.Check(chartVersion.Annotations)
+			if len(violations) == 0 {
+				continue
+			}
+			policyViolation := PolicyViolation{
+				Chart:      chartName,
+				Version:    chartVersion.Version,
+				Violations: violations,
+			}
+			if len(chartVersion.URLs) > 0 {
+				policyViolation.URL = chartVersion.URLs[0]
+			}
+			policyViolations = append(policyViolations, policyViolation)
+		}
+	}
+
+	return policyViolations
+}
@@ -0,0 +1,43 @@
+package validate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnnotationPolicyManaged(t *testing.T) {
+	policy := AnnotationPolicy{
+		Forbidden: []string{"catalog.cattle.io/*"},
+		Managed:   []string{"catalog.cattle.io/display-name"},
+	}
+
+	if policy.IsForbidden("catalog.cattle.io/display-name") {
+		t.Error("managed annotation is forbidden")
+	}
+	if !policy.IsForbidden("catalog.cattle.io/ui-component") {
+		t.Error("unmanaged annotation matching forbidden glob is allowed")
+	}
+
+	violations := policy.Check(map[string]string{
+		"catalog.cattle.io/display-name": "Foo",
+		"catalog.cattle.io/ui-component": "foo",
+	})
+	expected := []string{"forbidden annotation catalog.cattle.io/ui-component is set"}
+	if !reflect.DeepEqual(violations, expected) {
+		t.Errorf("expected violations %v, got %v", expected, violations)
+	}
+}
+
+func TestAnnotationPolicyOverridable(t *testing.T) {
+	if !(AnnotationPolicy{}).IsOverridable("catalog.cattle.io/namespace") {
+		t.Error("annotation not overridable without Overridable list")
+	}
+
+	policy := AnnotationPolicy{Overridable: []string{"catalog.cattle.io/kube-*"}}
+	if !policy.IsOverridable("catalog.cattle.io/kube-version") {
+		t.Error("annotation matching Overridable glob is not overridable")
+	}
+	if policy.IsOverridable("catalog.cattle.io/namespace") {
+		t.Error("annotation not in Overridable list is overridable")
+	}
+}
@@ -12,14 +12,43 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/samuelattwood/partner-charts-ci/pkg/conform"
+	"github.com/samuelattwood/partner-charts-ci/pkg/parse"
 	"github.com/sirupsen/logrus"
 	"helm.sh/helm/v3/pkg/chart/loader"
 
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	//IconEmbed rewrites chart icons to data URIs
+	IconEmbed = "embed"
+	//IconStore stores chart icons in the repository icons directory
+	IconStore = "store"
+)
+
 type ConfigurationYaml struct {
-	Validate []ValidateUpstream
+	//AnnotationPolicy is enforced when conforming charts and validating the index
+	AnnotationPolicy AnnotationPolicy
+	//Extract limits the size, entries, and links extracted from chart archives
+	Extract conform.ExtractOptions
+	//Icons configures how chart icons are fetched and stored
+	Icons IconConfig
+	//KubeSchemas is the directory of Kubernetes JSON schemas used to validate rendered charts
+	KubeSchemas string
+	//Retention is the default retention policy of packages, applied by prune
+	Retention parse.Retention
+	//ShardIndex writes per-vendor index shards to the index directory alongside the repository index
+	ShardIndex bool
+	//ScanThreshold is the severity at or above which security scan findings skip a package
+	ScanThreshold string
+	Validate      []ValidateUpstream
+}
+
+type IconConfig struct {
+	//Mode is one of IconEmbed or IconStore. Icons are left unchanged if empty
+	Mode string
+	//MaxSize is the maximum icon size in bytes
+	MaxSize int64
 }
 
 type ValidateUpstream struct {